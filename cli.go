@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/viveknathani/numero/nparser"
+)
+
+// runCommand runs a command given on the command line and returns the exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "gen":
+		return runGen(args[1:])
+	case "fmt":
+		return runFmt(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "unknown command: "+args[0])
+	return 2
+}
+
+// runGen writes a Go function equivalent to an expression
+func runGen(args []string) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: numero gen [flags] expression")
+		flags.PrintDefaults()
+	}
+	pkg := flags.String("package", "main", "name of the generated package")
+	name := flags.String("name", "Eval", "name of the generated function")
+	params := flags.String("params", "", "comma separated order of the function parameters")
+	output := flags.String("o", "", "output file (default standard output)")
+	integerMode := flags.Bool("integer", false, "enable the integer operators")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	np := nparser.New(flags.Arg(0))
+	np.SetIntegerMode(*integerMode)
+	program, err := np.Compile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	options := nparser.GoOptions{Package: *pkg, Function: *name}
+	if *params != "" {
+		options.Parameters = strings.Split(*params, ",")
+		for i, param := range options.Parameters {
+			options.Parameters[i] = strings.TrimSpace(param)
+		}
+	}
+	source, err := program.GenerateGo(options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(source)
+		return 0
+	}
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// runFmt formats expressions canonically, the one given as argument or else one per line of the standard input
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: numero fmt [flags] [expression]")
+		flags.PrintDefaults()
+	}
+	integerMode := flags.Bool("integer", false, "enable the integer operators")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	format := func(expression string) bool {
+		np := nparser.New(expression)
+		np.SetIntegerMode(*integerMode)
+		formatted, err := np.Format()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, expression+": "+err.Error())
+			return false
+		}
+		fmt.Println(formatted)
+		return true
+	}
+
+	if flags.NArg() == 1 {
+		if !format(flags.Arg(0)) {
+			return 1
+		}
+		return 0
+	}
+
+	code := 0
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if !format(scanner.Text()) {
+			code = 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return code
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -15,14 +21,128 @@ import (
 	"github.com/gomarkdown/markdown"
 	"github.com/viveknathani/numero/nlog"
 	"github.com/viveknathani/numero/nparser"
+	"github.com/viveknathani/numero/nplot"
 )
 
-// EvalRequest is the request body for the /api/v1/eval endpoint
+// EvalRequest is the request body for the /api/v1/eval, /api/v1/render and /api/v1/compile endpoints
 type EvalRequest struct {
-	Expression string            `json:"expression"`
+	Expression  string            `json:"expression"`
+	Variables   nparser.Variables `json:"variables,omitempty"`
+	Seed        *int64            `json:"seed,omitempty"`
+	IntegerMode bool              `json:"integerMode,omitempty"`
+	Policy      string            `json:"policy,omitempty"`
+	Substitute  float64           `json:"substitute,omitempty"`
+	Syntax      string            `json:"syntax,omitempty"`
+}
+
+// EvalColumnsRequest is the request body for the /api/v1/eval/columns endpoint
+type EvalColumnsRequest struct {
+	Expression  string               `json:"expression"`
+	Columns     map[string][]float64 `json:"columns,omitempty"`
+	Seed        *int64               `json:"seed,omitempty"`
+	IntegerMode bool                 `json:"integerMode,omitempty"`
+	Policy      string               `json:"policy,omitempty"`
+	Substitute  float64              `json:"substitute,omitempty"`
+}
+
+// InspectRequest is the request body for the /api/v1/inspect endpoint
+type InspectRequest struct {
+	Expression  string            `json:"expression"`
+	Variables   nparser.Variables `json:"variables,omitempty"`
+	IntegerMode bool              `json:"integerMode,omitempty"`
+}
+
+// PlotRequest is the request body, or the query parameters, for the /api/v1/plot endpoint
+type PlotRequest struct {
+	Expression  string            `json:"expression" query:"expression"`
+	Variable    string            `json:"variable,omitempty" query:"variable"`
+	From        float64           `json:"from" query:"from"`
+	To          float64           `json:"to" query:"to"`
+	Samples     int               `json:"samples,omitempty" query:"samples"`
+	Variables   nparser.Variables `json:"variables,omitempty" query:"-"`
+	IntegerMode bool              `json:"integerMode,omitempty" query:"integerMode"`
+	Title       string            `json:"title,omitempty" query:"title"`
+}
+
+// TaylorRequest is the request body for the /api/v1/taylor endpoint
+type TaylorRequest struct {
+	Expression  string            `json:"expression"`
+	Variable    string            `json:"variable,omitempty"`
+	At          float64           `json:"at"`
+	Order       int               `json:"order"`
+	Variables   nparser.Variables `json:"variables,omitempty"`
+	IntegerMode bool              `json:"integerMode,omitempty"`
+}
+
+// Point is a sampled point in the response of the /api/v1/plot endpoint
+type Point struct {
+	X jsonFloat  `json:"x"`
+	Y *jsonFloat `json:"y"`
+}
+
+// EvalBatchRequest is the request body for the /api/v1/eval/batch endpoint, holding either items, whose
+// expression defaults to the shared one, or one expression with many sets of variables
+type EvalBatchRequest struct {
+	Items       []BatchItem         `json:"items,omitempty"`
+	Expression  string              `json:"expression,omitempty"`
+	Variables   []nparser.Variables `json:"variables,omitempty"`
+	Seed        *int64              `json:"seed,omitempty"`
+	IntegerMode bool                `json:"integerMode,omitempty"`
+	Policy      string              `json:"policy,omitempty"`
+	Substitute  float64             `json:"substitute,omitempty"`
+	Syntax      string              `json:"syntax,omitempty"`
+}
+
+// BatchItem is an expression to evaluate in a request to the /api/v1/eval/batch endpoint
+type BatchItem struct {
+	Expression string            `json:"expression,omitempty"`
 	Variables  nparser.Variables `json:"variables,omitempty"`
 }
 
+// SolveRequest is the request body for the /api/v1/solve endpoint, holding either an equation or a system of equations
+type SolveRequest struct {
+	Equation  string   `json:"equation,omitempty"`
+	Equations []string `json:"equations,omitempty"`
+}
+
+// Root is a complex root in the response of the /api/v1/solve endpoint
+type Root struct {
+	Re jsonFloat `json:"re"`
+	Im jsonFloat `json:"im"`
+}
+
+// RowError is a failed row or item in the response of the /api/v1/eval/columns and /api/v1/eval/batch endpoints
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// policies maps the names of the policies accepted by the API to the policies
+var policies = map[string]nparser.Policy{
+	"":           nparser.PropagatePolicy,
+	"propagate":  nparser.PropagatePolicy,
+	"error":      nparser.ErrorPolicy,
+	"substitute": nparser.SubstitutePolicy,
+}
+
+// jsonFloat is a number that encodes the values JSON has no numbers for as the strings
+// "NaN", "Infinity" and "-Infinity"
+type jsonFloat float64
+
+// MarshalJSON implements json.Marshaler
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	value := float64(f)
+	switch {
+	case math.IsNaN(value):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(value, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(value, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return strconv.AppendFloat(nil, value, 'g', -1, 64), nil
+}
+
 // sendStandardResponse sends a standard response
 func sendStandardResponse(
 	c *fiber.Ctx,
@@ -36,6 +156,251 @@ func sendStandardResponse(
 	})
 }
 
+// defaultPlotSamples and maxPlotSamples are the default and largest numbers of points sampled for a plot
+const (
+	defaultPlotSamples = 200
+	maxPlotSamples     = 10000
+)
+
+// evalTimeout bounds the time spent evaluating the expression of a request, or all of those of a batch
+const evalTimeout = 2 * time.Second
+
+// serverLimits are the limits on the requests to the web service
+type serverLimits struct {
+	// bodyLimit is the largest body of a request, in bytes, but for those of largeBodyRoutes
+	bodyLimit int
+	// batchBodyLimit is the largest body of a request to one of largeBodyRoutes, in bytes
+	batchBodyLimit int
+	// batchItems is the largest number of items of a batch
+	batchItems int
+}
+
+// readLimits reads the limits on the requests from the environment variables BODY_LIMIT,
+// BATCH_BODY_LIMIT and BATCH_ITEM_LIMIT, defaulting to 4 KB, 1 MB and 1000 items
+func readLimits() (serverLimits, error) {
+	limits := serverLimits{bodyLimit: 4 * 1024, batchBodyLimit: 1024 * 1024, batchItems: 1000}
+	for name, limit := range map[string]*int{
+		"BODY_LIMIT":       &limits.bodyLimit,
+		"BATCH_BODY_LIMIT": &limits.batchBodyLimit,
+		"BATCH_ITEM_LIMIT": &limits.batchItems,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return serverLimits{}, errors.New("invalid " + name + " " + strconv.Quote(value) + ", expected a positive integer")
+		}
+		*limit = n
+	}
+	return limits, nil
+}
+
+// largeBodyRoutes are the routes evaluating many rows or items, whose bodies are limited by batchBodyLimit
+var largeBodyRoutes = map[string]bool{
+	"/api/v1/eval/batch":   true,
+	"/api/v1/eval/columns": true,
+}
+
+// limitBody returns a middleware rejecting the requests with a body larger than the limit of their route
+func limitBody(limits serverLimits) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := limits.bodyLimit
+		if largeBodyRoutes[c.Path()] {
+			limit = limits.batchBodyLimit
+		}
+		if len(c.Body()) > limit {
+			return sendStandardResponse(c, fiber.StatusRequestEntityTooLarge, nil,
+				"the request body is larger than "+strconv.Itoa(limit)+" bytes")
+		}
+		return c.Next()
+	}
+}
+
+// errorStatus maps an error of parsing or evaluation to the status code of its response
+func errorStatus(err error) int {
+	var exceeded nparser.ErrLimitExceeded
+	switch {
+	case errors.As(err, &exceeded):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, context.DeadlineExceeded):
+		return fiber.StatusRequestTimeout
+	}
+	return fiber.StatusBadRequest
+}
+
+// checkOptions checks the policy and the syntax named in a request
+func checkOptions(policy, syntax string) error {
+	if _, ok := policies[policy]; !ok {
+		return errors.New("unknown policy " + policy)
+	}
+	switch syntax {
+	case "", "native", "latex":
+		return nil
+	}
+	return errors.New("unknown syntax " + syntax)
+}
+
+// compile compiles the expression of a request, within the default limits
+func compile(req *EvalRequest) (*nparser.Program, error) {
+	if err := checkOptions(req.Policy, req.Syntax); err != nil {
+		return nil, err
+	}
+	policy := policies[req.Policy]
+
+	np := nparser.New(req.Expression)
+	np.SetIntegerMode(req.IntegerMode)
+	np.SetLimits(nparser.DefaultLimits)
+	np.SetPolicy(policy, req.Substitute)
+	if req.Syntax == "latex" {
+		return np.CompileLaTeX()
+	}
+	return np.Compile()
+}
+
+// evaluate compiles and evaluates the expression of a request, within the default limits
+func evaluate(ctx context.Context, req *EvalRequest) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, evalTimeout)
+	defer cancel()
+
+	program, err := compile(req)
+	if err != nil {
+		return 0, err
+	}
+	env := program.NewEnv()
+	if req.Seed != nil {
+		env.SetSeed(*req.Seed)
+	}
+	if err := program.SetVariables(env, req.Variables); err != nil {
+		return 0, err
+	}
+	return program.EvalContext(ctx, env)
+}
+
+// evaluateBatch evaluates the items of a batch in order, compiling every distinct expression once. The
+// failed items are nil in the results and reported in the errors, and the batch fails as a whole when
+// it runs out of time.
+func evaluateBatch(ctx context.Context, req *EvalBatchRequest) ([]*jsonFloat, []RowError, error) {
+	ctx, cancel := context.WithTimeout(ctx, evalTimeout)
+	defer cancel()
+
+	// the programs of the distinct expressions, along with an environment or the error compiling them
+	type compiled struct {
+		program *nparser.Program
+		env     *nparser.Env
+		err     error
+	}
+	programs := make(map[string]*compiled)
+	results := make([]*jsonFloat, len(req.Items))
+	failed := []RowError{}
+	for i, item := range req.Items {
+		expression := item.Expression
+		if expression == "" {
+			expression = req.Expression
+		}
+		entry, ok := programs[expression]
+		if !ok {
+			itemReq := &EvalRequest{
+				Expression:  expression,
+				IntegerMode: req.IntegerMode,
+				Policy:      req.Policy,
+				Substitute:  req.Substitute,
+				Syntax:      req.Syntax,
+			}
+			entry = &compiled{}
+			entry.program, entry.err = compile(itemReq)
+			if entry.err == nil {
+				// seeded once, so that the random functions of an expression draw a new number for every item
+				entry.env = entry.program.NewEnv()
+				if req.Seed != nil {
+					entry.env.SetSeed(*req.Seed)
+				}
+			}
+			programs[expression] = entry
+		}
+
+		err := entry.err
+		var result float64
+		if err == nil {
+			if err = entry.program.SetVariables(entry.env, item.Variables); err == nil {
+				result, err = entry.program.EvalContext(ctx, entry.env)
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		if err != nil {
+			failed = append(failed, RowError{Row: i, Error: err.Error()})
+			continue
+		}
+		value := jsonFloat(result)
+		results[i] = &value
+	}
+	return results, failed, nil
+}
+
+// plot samples the expression of a plot request and responds with the points as JSON or CSV, or a chart in SVG
+func plot(c *fiber.Ctx, req *PlotRequest) error {
+	if req.Samples == 0 {
+		req.Samples = defaultPlotSamples
+	}
+	if req.Samples > maxPlotSamples {
+		return sendStandardResponse(c, fiber.StatusBadRequest, nil, "at most "+strconv.Itoa(maxPlotSamples)+" samples can be plotted")
+	}
+	if math.IsNaN(req.From) || math.IsNaN(req.To) || math.IsInf(req.From, 0) || math.IsInf(req.To, 0) {
+		return sendStandardResponse(c, fiber.StatusBadRequest, nil, "the range to plot must be finite")
+	}
+	if req.From >= req.To {
+		return sendStandardResponse(c, fiber.StatusBadRequest, nil, "the range to plot must be increasing")
+	}
+
+	np := nparser.New(req.Expression)
+	np.SetIntegerMode(req.IntegerMode)
+	np.SetLimits(nparser.DefaultLimits)
+	program, err := np.Compile()
+	if err != nil {
+		return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+	}
+	// the only variable of the expression is plotted by default
+	if req.Variable == "" {
+		req.Variable = "x"
+		if variables := program.Variables(); len(variables) == 1 {
+			req.Variable = variables[0]
+		}
+	}
+	ctx, cancel := context.WithTimeout(c.UserContext(), evalTimeout)
+	defer cancel()
+	points, err := program.SampleContext(ctx, req.Variable, req.From, req.To, req.Samples, req.Variables)
+	if err != nil {
+		return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+	}
+
+	switch format := c.Query("format", "json"); format {
+	case "json":
+		// the gaps are null
+		sampled := make([]Point, len(points))
+		for i, point := range points {
+			sampled[i].X = jsonFloat(point.X)
+			if !math.IsNaN(point.Y) {
+				sampled[i].Y = (*jsonFloat)(&points[i].Y)
+			}
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"variable": req.Variable,
+			"points":   sampled,
+		}, "success")
+	case "csv":
+		c.Set("Content-Type", "text/csv")
+		return c.Send(nplot.CSV(points))
+	case "svg":
+		c.Set("Content-Type", "image/svg+xml")
+		return c.Send(nplot.SVG(points, nplot.Options{Title: req.Title}))
+	default:
+		return sendStandardResponse(c, fiber.StatusBadRequest, nil, "unknown format "+format)
+	}
+}
+
 // handle404 handles 404 errors
 func handle404(c *fiber.Ctx) error {
 	return sendStandardResponse(c, fiber.StatusNotFound, nil, "you seem lost!")
@@ -49,22 +414,35 @@ var evalPool = sync.Pool{
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	nlog.Info("hello from numero!")
 
 	PORT := "8084"
 
+	limits, err := readLimits()
+	if err != nil {
+		nlog.Error(err.Error())
+		os.Exit(1)
+	}
+
 	app := fiber.New(fiber.Config{
 		Prefork:      true,
 		ServerHeader: "numero",
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 		IdleTimeout:  10 * time.Second,
-		BodyLimit:    4 * 1024,
-		Concurrency:  256 * 1024,
+		// the limit of every route is checked by limitBody
+		BodyLimit:   max(limits.bodyLimit, limits.batchBodyLimit),
+		Concurrency: 256 * 1024,
 	})
 
 	app.Use(recover.New())
 
+	app.Use(limitBody(limits))
+
 	app.Use(compress.New(compress.Config{
 		Level: compress.LevelBestSpeed,
 	}))
@@ -119,24 +497,310 @@ func main() {
 
 		req.Expression = ""
 		req.Variables = nil
+		req.Seed = nil
+		req.IntegerMode = false
+		req.Policy = ""
+		req.Substitute = 0
+		req.Syntax = ""
+
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+
+		result, err := evaluate(c.UserContext(), req)
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"result": jsonFloat(result),
+		}, "success")
+	})
+
+	app.Post("/api/v1/eval/batch", func(c *fiber.Ctx) error {
+		req := new(EvalBatchRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+		if len(req.Items) > 0 && len(req.Variables) > 0 {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, "expected either items or sets of variables")
+		}
+		if err := checkOptions(req.Policy, req.Syntax); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+		for _, variables := range req.Variables {
+			req.Items = append(req.Items, BatchItem{Variables: variables})
+		}
+		if len(req.Items) > limits.batchItems {
+			return sendStandardResponse(c, fiber.StatusRequestEntityTooLarge, nil,
+				"at most "+strconv.Itoa(limits.batchItems)+" items can be evaluated in a batch")
+		}
 
+		results, failed, err := evaluateBatch(c.UserContext(), req)
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"results": results,
+			"errors":  failed,
+		}, "success")
+	})
+
+	app.Post("/api/v1/render", func(c *fiber.Ctx) error {
+		req := new(EvalRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+
+		np := nparser.New(req.Expression)
+		np.SetIntegerMode(req.IntegerMode)
+		np.SetLimits(nparser.DefaultLimits)
+		var tree *nparser.Node
+		var err error
+		if req.Syntax == "latex" {
+			tree, err = np.ParseLaTeX()
+		} else {
+			tree, err = np.Parse()
+		}
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		format := c.Query("format", "latex")
+		var rendered string
+		switch format {
+		case "latex":
+			rendered = tree.LaTeX()
+		case "mathml":
+			rendered = tree.MathML()
+		default:
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, "unknown format "+format)
+		}
+
+		result, err := evaluate(c.UserContext(), req)
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"format":   format,
+			"rendered": rendered,
+			"result":   jsonFloat(result),
+		}, "success")
+	})
+
+	app.Post("/api/v1/eval/columns", func(c *fiber.Ctx) error {
+		req := new(EvalColumnsRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+
+		policy, ok := policies[req.Policy]
+		if !ok {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, "unknown policy "+req.Policy)
+		}
+
+		np := nparser.New(req.Expression)
+		np.SetIntegerMode(req.IntegerMode)
+		np.SetLimits(nparser.DefaultLimits)
+		np.SetPolicy(policy, req.Substitute)
+		program, err := np.Compile()
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		ctx, cancel := context.WithTimeout(c.UserContext(), evalTimeout)
+		defer cancel()
+		env := program.NewEnv()
+		if req.Seed != nil {
+			env.SetSeed(*req.Seed)
+		}
+		results, err := program.EvalColumnsEnv(ctx, env, req.Columns)
+		var rowErrors nparser.ErrRows
+		if err != nil && !errors.As(err, &rowErrors) {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+
+		// failed rows are null in the results
+		values := make([]*jsonFloat, len(results))
+		for i := range results {
+			values[i] = (*jsonFloat)(&results[i])
+		}
+		failed := make([]RowError, len(rowErrors.Errors))
+		for i, rowError := range rowErrors.Errors {
+			values[rowError.Row] = nil
+			failed[i] = RowError{Row: rowError.Row, Error: rowError.Err.Error()}
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"results": values,
+			"errors":  failed,
+		}, "success")
+	})
+
+	app.Post("/api/v1/inspect", func(c *fiber.Ctx) error {
+		req := new(InspectRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+
+		np := nparser.New(req.Expression)
+		np.SetIntegerMode(req.IntegerMode)
+		np.SetLimits(nparser.DefaultLimits)
+		program, err := np.Compile()
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+
+		// empty lists rather than null, for clients iterating over them
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"variables": append([]string{}, program.Variables()...),
+			"functions": append([]string{}, program.Functions()...),
+			"missing":   append([]string{}, program.Missing(req.Variables)...),
+		}, "success")
+	})
+
+	app.Post("/api/v1/compile", func(c *fiber.Ctx) error {
+		req := new(EvalRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+
+		program, err := compile(req)
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		format := c.Query("format", "json")
+		var serialized interface{}
+		switch format {
+		case "json":
+			data, err := program.MarshalJSON()
+			if err != nil {
+				return sendStandardResponse(c, fiber.StatusInternalServerError, nil, err.Error())
+			}
+			serialized = json.RawMessage(data)
+		case "binary":
+			data, err := program.MarshalBinary()
+			if err != nil {
+				return sendStandardResponse(c, fiber.StatusInternalServerError, nil, err.Error())
+			}
+			serialized = base64.StdEncoding.EncodeToString(data)
+		default:
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, "unknown format "+format)
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"format":  format,
+			"program": serialized,
+		}, "success")
+	})
+
+	app.Post("/api/v1/taylor", func(c *fiber.Ctx) error {
+		req := new(TaylorRequest)
 		if err := c.BodyParser(req); err != nil {
 			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
 		}
 
 		np := nparser.New(req.Expression)
-		for name, value := range req.Variables {
-			np.SetVariable(name, value)
+		np.SetIntegerMode(req.IntegerMode)
+		np.SetLimits(nparser.DefaultLimits)
+		program, err := np.Compile()
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		// the only variable of the expression is expanded by default
+		if req.Variable == "" {
+			req.Variable = "x"
+			if variables := program.Variables(); len(variables) == 1 {
+				req.Variable = variables[0]
+			}
 		}
-		result, err := np.Run()
+		series, err := program.Taylor(req.Variable, req.At, req.Order, req.Variables)
 		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+
+		// the derivatives are the coefficients multiplied by the factorial of their order
+		coefficients := make([]jsonFloat, len(series.Coefficients))
+		derivatives := make([]jsonFloat, len(series.Coefficients))
+		factorial := 1.0
+		for k, coefficient := range series.Coefficients {
+			if k > 0 {
+				factorial *= float64(k)
+			}
+			coefficients[k] = jsonFloat(coefficient)
+			derivatives[k] = jsonFloat(coefficient * factorial)
+		}
+		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+			"variable":     req.Variable,
+			"polynomial":   series.String(),
+			"coefficients": coefficients,
+			"derivatives":  derivatives,
+		}, "success")
+	})
+
+	app.Post("/api/v1/solve", func(c *fiber.Ctx) error {
+		req := new(SolveRequest)
+		if err := c.BodyParser(req); err != nil {
 			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
 		}
+		if (req.Equation == "") == (len(req.Equations) == 0) {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, "expected either an equation or equations")
+		}
+
+		if req.Equation != "" {
+			np := nparser.New(req.Equation)
+			np.SetLimits(nparser.DefaultLimits)
+			solution, err := np.Solve()
+			if err != nil {
+				return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+			}
+			roots := make([]Root, len(solution.Roots))
+			for i, root := range solution.Roots {
+				roots[i] = Root{Re: jsonFloat(real(root)), Im: jsonFloat(imag(root))}
+			}
+			return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
+				"variable": solution.Variable,
+				"degree":   solution.Degree,
+				"exact":    solution.Exact,
+				"identity": solution.Identity,
+				"roots":    roots,
+			}, "success")
+		}
+
+		equations := make([]*nparser.Equation, len(req.Equations))
+		for i, equation := range req.Equations {
+			np := nparser.New(equation)
+			np.SetLimits(nparser.DefaultLimits)
+			parsed, err := np.ParseEquation()
+			if err != nil {
+				return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+			}
+			equations[i] = parsed
+		}
+		solution, err := nparser.SolveLinear(equations)
+		if err != nil {
+			return sendStandardResponse(c, errorStatus(err), nil, err.Error())
+		}
+		values := make(map[string]jsonFloat, len(solution))
+		for name, value := range solution {
+			values[name] = jsonFloat(value)
+		}
 		return sendStandardResponse(c, fiber.StatusOK, &map[string]interface{}{
-			"result": result,
+			"solution": values,
 		}, "success")
 	})
 
+	app.Get("/api/v1/plot", func(c *fiber.Ctx) error {
+		req := new(PlotRequest)
+		if err := c.QueryParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+		return plot(c, req)
+	})
+
+	app.Post("/api/v1/plot", func(c *fiber.Ctx) error {
+		req := new(PlotRequest)
+		if err := c.BodyParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+		return plot(c, req)
+	})
+
 	app.Use(handle404)
 
 	done := make(chan os.Signal, 1)
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPlotRejectsRangesThatAreNotFinite(t *testing.T) {
+	app := fiber.New()
+	app.Get("/plot", func(c *fiber.Ctx) error {
+		req := new(PlotRequest)
+		if err := c.QueryParser(req); err != nil {
+			return sendStandardResponse(c, fiber.StatusBadRequest, nil, err.Error())
+		}
+		return plot(c, req)
+	})
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"from=0&to=1", fiber.StatusOK},
+		{"from=NaN&to=1", fiber.StatusBadRequest},
+		{"from=0&to=NaN", fiber.StatusBadRequest},
+		{"from=-Inf&to=1", fiber.StatusBadRequest},
+		{"from=0&to=Inf", fiber.StatusBadRequest},
+		{"from=1&to=0", fiber.StatusBadRequest},
+	}
+	for _, test := range tests {
+		res, err := app.Test(httptest.NewRequest("GET", "/plot?expression=x&samples=4&"+test.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.status {
+			t.Errorf("%s: expected the status %d, got %d", test.query, test.status, res.StatusCode)
+		}
+	}
+}
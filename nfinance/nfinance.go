@@ -0,0 +1,118 @@
+package nfinance
+
+import "math"
+
+// maxIterations bounds the Newton iterations used by Rate and Irr
+const maxIterations = 100
+
+// tolerance is the step size below which Rate and Irr consider themselves converged
+const tolerance = 1e-10
+
+// Pmt returns the payment per period of a loan or annuity with a constant rate,
+// like the spreadsheet PMT function. typ is 0 when payments are due at the end
+// of each period and 1 when they are due at the beginning.
+func Pmt(rate, nper, pv, fv, typ float64) float64 {
+	if rate == 0 {
+		return -(pv + fv) / nper
+	}
+	f := math.Pow(1+rate, nper)
+	return -rate * (pv*f + fv) / ((1 + rate*typ) * (f - 1))
+}
+
+// Fv returns the future value of an investment, like the spreadsheet FV function
+func Fv(rate, nper, pmt, pv, typ float64) float64 {
+	if rate == 0 {
+		return -(pv + pmt*nper)
+	}
+	f := math.Pow(1+rate, nper)
+	return -(pv*f + pmt*(1+rate*typ)*(f-1)/rate)
+}
+
+// Pv returns the present value of an investment, like the spreadsheet PV function
+func Pv(rate, nper, pmt, fv, typ float64) float64 {
+	if rate == 0 {
+		return -(fv + pmt*nper)
+	}
+	f := math.Pow(1+rate, nper)
+	return -(fv + pmt*(1+rate*typ)*(f-1)/rate) / f
+}
+
+// Nper returns the number of periods of an investment, like the spreadsheet NPER function
+func Nper(rate, pmt, pv, fv, typ float64) float64 {
+	if rate == 0 {
+		return -(pv + fv) / pmt
+	}
+	z := pmt * (1 + rate*typ) / rate
+	return math.Log((z-fv)/(z+pv)) / math.Log(1+rate)
+}
+
+// Rate returns the interest rate per period of an annuity, like the spreadsheet
+// RATE function. It returns NaN when the iteration starting at guess does not converge.
+func Rate(nper, pmt, pv, fv, typ, guess float64) float64 {
+	rate := guess
+	for i := 0; i < maxIterations; i++ {
+		if rate <= -1 || math.IsNaN(rate) {
+			return math.NaN()
+		}
+		var y, dy float64
+		if math.Abs(rate) < 1e-12 {
+			// the limit of the annuity equation as the rate tends to zero
+			y = pv + pmt*nper + fv
+			dy = pv*nper + pmt*(typ*nper+nper*(nper-1)/2)
+		} else {
+			f := math.Pow(1+rate, nper)
+			df := nper * math.Pow(1+rate, nper-1)
+			y = pv*f + pmt*(1+rate*typ)*(f-1)/rate + fv
+			dy = pv*df + pmt*(typ*(f-1)/rate+(1+rate*typ)*(df*rate-(f-1))/(rate*rate))
+		}
+		step := y / dy
+		rate -= step
+		if math.Abs(step) < tolerance {
+			return rate
+		}
+	}
+	return math.NaN()
+}
+
+// Npv returns the net present value of cash flows occurring at the end of
+// consecutive periods, like the spreadsheet NPV function
+func Npv(rate float64, values ...float64) float64 {
+	result := 0.0
+	for i, value := range values {
+		result += value / math.Pow(1+rate, float64(i+1))
+	}
+	return result
+}
+
+// Irr returns the internal rate of return of cash flows occurring at regular
+// periods, like the spreadsheet IRR function. It returns NaN when the cash flows
+// do not change sign or when the iteration starting at guess does not converge.
+func Irr(values []float64, guess float64) float64 {
+	positive, negative := false, false
+	for _, value := range values {
+		positive = positive || value > 0
+		negative = negative || value < 0
+	}
+	if !positive || !negative {
+		return math.NaN()
+	}
+
+	rate := guess
+	for i := 0; i < maxIterations; i++ {
+		if rate <= -1 || math.IsNaN(rate) {
+			return math.NaN()
+		}
+		var y, dy float64
+		for period, value := range values {
+			t := float64(period)
+			y += value / math.Pow(1+rate, t)
+			dy -= t * value / math.Pow(1+rate, t+1)
+		}
+		step := y / dy
+		rate -= step
+		if math.Abs(step) < tolerance {
+			return rate
+		}
+	}
+	return math.NaN()
+}
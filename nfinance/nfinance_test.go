@@ -0,0 +1,135 @@
+package nfinance
+
+import (
+	"math"
+	"testing"
+)
+
+// the expected values are the outputs of the equivalent spreadsheet formulas
+func TestPmt(t *testing.T) {
+	tests := []struct {
+		rate, nper, pv, fv, typ float64
+		want                    float64
+	}{
+		{0.08 / 12, 10, 10000, 0, 0, -1037.03},
+		{0.08 / 12, 10, 10000, 0, 1, -1030.16},
+		{0.06 / 12, 18 * 12, 0, 50000, 0, -129.08},
+		{0, 10, 1000, 0, 0, -100},
+	}
+	for _, tt := range tests {
+		got := Pmt(tt.rate, tt.nper, tt.pv, tt.fv, tt.typ)
+		if math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("Pmt(%v, %v, %v, %v, %v) = %v, want %v", tt.rate, tt.nper, tt.pv, tt.fv, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestFv(t *testing.T) {
+	tests := []struct {
+		rate, nper, pmt, pv, typ float64
+		want                     float64
+	}{
+		{0.06 / 12, 10, -200, -500, 1, 2581.40},
+		{0.12 / 12, 12, -1000, 0, 0, 12682.50},
+		{0.11 / 12, 35, -2000, 0, 1, 82846.25},
+		{0, 10, -100, -1000, 0, 2000},
+	}
+	for _, tt := range tests {
+		got := Fv(tt.rate, tt.nper, tt.pmt, tt.pv, tt.typ)
+		if math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("Fv(%v, %v, %v, %v, %v) = %v, want %v", tt.rate, tt.nper, tt.pmt, tt.pv, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestPv(t *testing.T) {
+	tests := []struct {
+		rate, nper, pmt, fv, typ float64
+		want                     float64
+	}{
+		{0.08 / 12, 12 * 20, 500, 0, 0, -59777.15},
+		{0.1, 5, 0, 10000, 0, -6209.21},
+		{0, 10, -100, 0, 0, 1000},
+	}
+	for _, tt := range tests {
+		got := Pv(tt.rate, tt.nper, tt.pmt, tt.fv, tt.typ)
+		if math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("Pv(%v, %v, %v, %v, %v) = %v, want %v", tt.rate, tt.nper, tt.pmt, tt.fv, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestNper(t *testing.T) {
+	tests := []struct {
+		rate, pmt, pv, fv, typ float64
+		want                   float64
+	}{
+		{0.12 / 12, -100, -1000, 10000, 1, 59.6738657},
+		{0.12 / 12, -100, -1000, 10000, 0, 60.0821229},
+		{0.12 / 12, -100, -1000, 0, 0, -9.5785940},
+		{0, -100, 1000, 0, 0, 10},
+	}
+	for _, tt := range tests {
+		got := Nper(tt.rate, tt.pmt, tt.pv, tt.fv, tt.typ)
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("Nper(%v, %v, %v, %v, %v) = %v, want %v", tt.rate, tt.pmt, tt.pv, tt.fv, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		nper, pmt, pv, fv, typ, guess float64
+		want                          float64
+	}{
+		{4 * 12, -200, 8000, 0, 0, 0.1, 0.0077014725},
+		{10, 0, -1000, 2000, 0, 0.1, 0.0717734625},
+		{10, -100, 1000, 0, 0, 0.1, 0},
+	}
+	for _, tt := range tests {
+		got := Rate(tt.nper, tt.pmt, tt.pv, tt.fv, tt.typ, tt.guess)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Rate(%v, %v, %v, %v, %v, %v) = %v, want %v", tt.nper, tt.pmt, tt.pv, tt.fv, tt.typ, tt.guess, got, tt.want)
+		}
+	}
+}
+
+func TestNpv(t *testing.T) {
+	tests := []struct {
+		rate   float64
+		values []float64
+		want   float64
+	}{
+		{0.1, []float64{-10000, 3000, 4200, 6800}, 1188.44},
+		{0.08, []float64{8000, 9200, 10000, 12000, 14500}, 41922.06},
+		{0, []float64{1, 2, 3}, 6},
+	}
+	for _, tt := range tests {
+		got := Npv(tt.rate, tt.values...)
+		if math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("Npv(%v, %v) = %v, want %v", tt.rate, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestIrr(t *testing.T) {
+	tests := []struct {
+		values []float64
+		guess  float64
+		want   float64
+	}{
+		{[]float64{-70000, 12000, 15000, 18000, 21000, 26000}, 0.1, 0.0866309480},
+		{[]float64{-70000, 12000, 15000, 18000, 21000}, 0.1, -0.0212448482},
+		{[]float64{-70000, 12000, 15000}, -0.1, -0.4435069413},
+	}
+	for _, tt := range tests {
+		got := Irr(tt.values, tt.guess)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Irr(%v, %v) = %v, want %v", tt.values, tt.guess, got, tt.want)
+		}
+	}
+
+	if got := Irr([]float64{100, 200}, 0.1); !math.IsNaN(got) {
+		t.Errorf("Irr without a sign change = %v, want NaN", got)
+	}
+}
@@ -0,0 +1,150 @@
+package nparser
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/viveknathani/numero/nqueue"
+	"github.com/viveknathani/numero/nstack"
+)
+
+// NodeKind is the kind of a node in an expression tree
+type NodeKind int
+
+const (
+	// NumberNode is a numeric literal
+	NumberNode NodeKind = iota
+
+	// VariableNode is a reference to a variable
+	VariableNode
+
+	// OperatorNode is an operator applied to one operand (prefix) or two operands
+	OperatorNode
+
+	// CallNode is a function call
+	CallNode
+)
+
+// Node is a node in an expression tree
+type Node struct {
+	Kind NodeKind
+	// Value is the value of a number
+	Value float64
+	// Name is the name of a variable, operator or function
+	Name string
+	// Args are the operands of an operator or the arguments of a function, as written
+	Args []*Node
+}
+
+// Parse parses the expression to a tree, without evaluating it
+func (np *Nparser) Parse() (*Node, error) {
+	rpn, err := np.parse()
+	if err != nil {
+		return nil, err
+	}
+	return np.buildTree(rpn)
+}
+
+// buildTree converts an expression in reverse polish notation to a tree
+func (np *Nparser) buildTree(rpn *nqueue.NQueue[rpnToken]) (*Node, error) {
+	stack := nstack.New[*Node]()
+
+	for {
+		item, err := rpn.Dequeue()
+		if err != nil {
+			break
+		}
+		token := item.token
+
+		if item.call {
+			args := make([]*Node, item.argc)
+			for i := item.argc - 1; i >= 0; i-- {
+				arg, err := stack.Pop()
+				if err != nil {
+					return nil, ErrNotEnoughOperandsForFunction{Function: string(token)}
+				}
+				args[i] = arg
+			}
+			stack.Push(&Node{Kind: CallNode, Name: string(token), Args: args})
+			continue
+		}
+
+		if np.isUnary(token) {
+			a, err := stack.Pop()
+			if err != nil {
+				if token == UMINUS {
+					return nil, ErrUnaryMinusMissingOperand{}
+				}
+				return nil, ErrNotEnoughOperands{}
+			}
+			if token == SQRT {
+				// the square root sign is only notation for the sqrt function
+				stack.Push(&Node{Kind: CallNode, Name: "sqrt", Args: []*Node{a}})
+			} else {
+				stack.Push(&Node{Kind: OperatorNode, Name: string(token), Args: []*Node{a}})
+			}
+			continue
+		}
+
+		if np.isAnOperator(token) {
+			b, err1 := stack.Pop()
+			a, err2 := stack.Pop()
+			if err1 != nil || err2 != nil {
+				return nil, ErrNotEnoughOperands{}
+			}
+			stack.Push(&Node{Kind: OperatorNode, Name: string(token), Args: []*Node{a, b}})
+			continue
+		}
+
+		if num, err := strconv.ParseFloat(string(token), 64); err == nil {
+			stack.Push(&Node{Kind: NumberNode, Value: num})
+		} else {
+			stack.Push(&Node{Kind: VariableNode, Name: string(token)})
+		}
+	}
+
+	root, err := stack.Pop()
+	if err != nil {
+		return nil, ErrEmptyStack{}
+	}
+	if _, err := stack.Top(); err == nil {
+		return nil, ErrTooManyOperands{}
+	}
+
+	return root, nil
+}
+
+// functions appends the names of the functions the tree calls that are not listed yet
+func (node *Node) functions(names []string) []string {
+	if node.Kind == CallNode && !slices.Contains(names, node.Name) {
+		names = append(names, node.Name)
+	}
+	for _, arg := range node.Args {
+		names = arg.functions(names)
+	}
+	return names
+}
+
+// variables appends the names of the variables the tree references that are not listed yet
+func (node *Node) variables(names []string) []string {
+	if node.Kind == VariableNode && !slices.Contains(names, node.Name) {
+		names = append(names, node.Name)
+	}
+	for _, arg := range node.Args {
+		names = arg.variables(names)
+	}
+	return names
+}
+
+// isVolatile checks if the tree calls a random function anywhere
+func (node *Node) isVolatile() bool {
+	if node.Kind == CallNode && functionList[node.Name].randFn != nil {
+		return true
+	}
+	for _, arg := range node.Args {
+		if arg.isVolatile() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package nparser
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// batchChunk is the number of rows a worker takes at a time
+const batchChunk = 64
+
+// BatchOptions configures a batch evaluation
+type BatchOptions struct {
+	// Workers is the number of goroutines evaluating rows, GOMAXPROCS if zero
+	Workers int
+	// Seed, if set, makes the numbers drawn by random functions reproducible whatever the number
+	// of workers, every chunk of rows drawing from a source seeded with it plus its first row
+	Seed *int64
+}
+
+// BatchResult is the result of a single row of a batch evaluation
+type BatchResult struct {
+	Value float64
+	Err   error
+}
+
+// EvalBatch evaluates the program for every row of variables on a pool of workers. The
+// results are in the order of the rows. If the context is cancelled, the rows that were
+// not evaluated fail with the error of the context, which EvalBatch also returns when
+// there are any.
+func (p *Program) EvalBatch(ctx context.Context, rows []Variables, opts BatchOptions) ([]BatchResult, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, (len(rows)+batchChunk-1)/batchChunk)
+
+	results := make([]BatchResult, len(rows))
+	var next atomic.Int64
+	// skipped records that rows were left out because the context was cancelled
+	var skipped atomic.Bool
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env := p.NewEnv()
+			for {
+				start := int(next.Add(batchChunk)) - batchChunk
+				if start >= len(rows) {
+					return
+				}
+				end := min(start+batchChunk, len(rows))
+				if err := ctx.Err(); err != nil {
+					skipped.Store(true)
+					for i := start; i < end; i++ {
+						results[i].Err = err
+					}
+					continue
+				}
+				if opts.Seed != nil {
+					env.random().Seed(*opts.Seed + int64(start))
+				}
+				for i := start; i < end; i++ {
+					if err := p.SetVariables(env, rows[i]); err != nil {
+						results[i].Err = err
+						continue
+					}
+					results[i].Value, results[i].Err = p.Eval(env)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if skipped.Load() {
+		return results, ctx.Err()
+	}
+	return results, nil
+}
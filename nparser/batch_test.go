@@ -0,0 +1,145 @@
+package nparser
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEvalBatchPreservesOrder(t *testing.T) {
+	program, err := Compile("x * 2 + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]Variables, 1000)
+	for i := range rows {
+		rows[i] = Variables{"x": float64(i), "y": 1}
+	}
+	rows[500] = Variables{"x": 1}
+
+	results, err := program.EvalBatch(context.Background(), rows, BatchOptions{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if i == 500 {
+			if !errors.As(result.Err, &ErrUndefinedVariable{}) {
+				t.Errorf("expected row 500 to fail with ErrUndefinedVariable, got %v", result.Err)
+			}
+			continue
+		}
+		if result.Err != nil || result.Value != float64(i*2+1) {
+			t.Errorf("row %d: expected %d, got %v (%v)", i, i*2+1, result.Value, result.Err)
+		}
+	}
+}
+
+func TestEvalBatchWithSeed(t *testing.T) {
+	program, err := Compile("x + rand() + normal(0, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]Variables, 500)
+	for i := range rows {
+		rows[i] = Variables{"x": float64(i)}
+	}
+
+	seed := int64(7)
+	expected, err := program.EvalBatch(context.Background(), rows, BatchOptions{Workers: 1, Seed: &seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 3, 8} {
+		results, err := program.EvalBatch(context.Background(), rows, BatchOptions{Workers: workers, Seed: &seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(results, expected) {
+			t.Errorf("expected the same results for the same seed with %d workers", workers)
+		}
+	}
+	// rows 0 and 1 differ by the numbers they drew, apart from x
+	if expected[0].Value == expected[1].Value-1 {
+		t.Error("expected the rows to draw different numbers")
+	}
+}
+
+func TestEvalBatchCancelled(t *testing.T) {
+	program, err := Compile("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]Variables, 200)
+	for i := range rows {
+		rows[i] = Variables{"x": float64(i)}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := program.EvalBatch(ctx, rows, BatchOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("row %d: expected context.Canceled, got %v", i, result.Err)
+		}
+	}
+}
+
+// expiringContext is a context that is cancelled once its error has been checked a number of times
+type expiringContext struct {
+	context.Context
+	checks atomic.Int64
+	limit  int64
+}
+
+func (ctx *expiringContext) Err() error {
+	if ctx.checks.Add(1) > ctx.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestEvalBatchCancelledAfterEveryRow(t *testing.T) {
+	program, err := Compile("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]Variables, 4*batchChunk)
+	for i := range rows {
+		rows[i] = Variables{"x": float64(i)}
+	}
+	// cancelled once every chunk has been taken
+	ctx := &expiringContext{Context: context.Background(), limit: 4}
+
+	results, err := program.EvalBatch(ctx, rows, BatchOptions{Workers: 1})
+	if err != nil {
+		t.Fatalf("expected no error once every row is evaluated, got %v", err)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Value != float64(i+1) {
+			t.Errorf("row %d: expected %d, got %f and %v", i, i+1, result.Value, result.Err)
+		}
+	}
+}
+
+func BenchmarkEvalBatch(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rows := make([]Variables, 100000)
+	for i := range rows {
+		rows[i] = benchmarkVariables
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.EvalBatch(context.Background(), rows, BatchOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package nparser
+
+import "math"
+
+// Bind substitutes the values of some of the variables of the program and folds the constants,
+// returning a residual program over the remaining variables with the same backend, limits and
+// policy. Values of variables the program does not reference are ignored, and values that are
+// not finite are bound as the divisions by zero giving them.
+func (p *Program) Bind(partial Variables) (*Program, error) {
+	root := fold(p.root.substitute(func(name string) *Node {
+		value, ok := partial[name]
+		if !ok {
+			return nil
+		}
+		return constantNode(value)
+	}))
+
+	residual := &Program{
+		expression: Expression(root.String()),
+		backend:    p.backend,
+		limits:     p.limits,
+		policy:     p.policy,
+	}
+	if err := residual.compile(root); err != nil {
+		return nil, err
+	}
+	return residual, nil
+}
+
+// constantNode returns the tree of a value, writing the values that are not finite as the
+// divisions by zero giving them, since they have no numbers in expressions
+func constantNode(value float64) *Node {
+	zero := &Node{Kind: NumberNode, Value: 0}
+	switch {
+	case math.IsNaN(value):
+		return operatorNode(DIV, zero, zero)
+	case math.IsInf(value, 1):
+		return operatorNode(DIV, &Node{Kind: NumberNode, Value: 1}, zero)
+	case math.IsInf(value, -1):
+		return operatorNode(UMINUS, operatorNode(DIV, &Node{Kind: NumberNode, Value: 1}, zero))
+	}
+	return &Node{Kind: NumberNode, Value: value}
+}
+
+// Substitute replaces a variable of the tree with another tree, returning the new tree
+func (node *Node) Substitute(name string, replacement *Node) *Node {
+	return node.substitute(func(variable string) *Node {
+		if variable != name {
+			return nil
+		}
+		return replacement
+	})
+}
+
+// substitute replaces the variables for which replace returns a tree, sharing the unchanged subtrees
+func (node *Node) substitute(replace func(name string) *Node) *Node {
+	switch node.Kind {
+	case NumberNode:
+		return node
+	case VariableNode:
+		if replacement := replace(node.Name); replacement != nil {
+			return replacement
+		}
+		return node
+	}
+
+	args := make([]*Node, len(node.Args))
+	changed := false
+	for i, arg := range node.Args {
+		args[i] = arg.substitute(replace)
+		changed = changed || args[i] != arg
+	}
+	if !changed {
+		return node
+	}
+	return &Node{Kind: node.Kind, Name: node.Name, Args: args}
+}
+
+// Substitute inlines an expression in place of a variable of another expression, returning
+// the result formatted canonically
+func Substitute(expression, name, replacement string) (string, error) {
+	root, err := New(expression).Parse()
+	if err != nil {
+		return "", err
+	}
+	inlined, err := New(replacement).Parse()
+	if err != nil {
+		return "", err
+	}
+	return root.Substitute(name, inlined).String(), nil
+}
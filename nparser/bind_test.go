@@ -0,0 +1,131 @@
+package nparser
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestProgramBind(t *testing.T) {
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		np := New("principal * (1 + rate / 12) ^ months + fee * 2")
+		np.SetBackend(backend)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		residual, err := program.Bind(Variables{"rate": 0.06, "fee": 5, "unused": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if residual.Backend() != backend {
+			t.Errorf("expected the backend to be kept")
+		}
+		if expected := []string{"principal", "months"}; !reflect.DeepEqual(residual.Variables(), expected) {
+			t.Errorf("expected variables %v, got %v", expected, residual.Variables())
+		}
+		if expected := "principal * 1.005 ^ months + 10"; residual.Expression() != expected {
+			t.Errorf("expected the residual %q, got %q", expected, residual.Expression())
+		}
+
+		full, err := program.Run(Variables{"principal": 1000, "rate": 0.06, "months": 12, "fee": 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := residual.Run(Variables{"principal": 1000, "months": 12})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(result-full) > 1e-9 {
+			t.Errorf("expected %v, got %v", full, result)
+		}
+	}
+}
+
+func TestProgramBindLimits(t *testing.T) {
+	np := New("2 ^ x")
+	np.SetLimits(DefaultLimits)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.Bind(Variables{"x": 5000}); err == nil {
+		t.Errorf("expected the exponent limit to apply to bound values")
+	}
+}
+
+func TestProgramBindNonFinite(t *testing.T) {
+	program, err := Compile("x + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value      float64
+		expression string
+	}{
+		{math.NaN(), "0 / 0 + y"},
+		{math.Inf(1), "1 / 0 + y"},
+		{math.Inf(-1), "-(1 / 0) + y"},
+	}
+	for _, test := range tests {
+		residual, err := program.Bind(Variables{"x": test.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if residual.Expression() != test.expression {
+			t.Errorf("%v: expected %s, got %s", test.value, test.expression, residual.Expression())
+		}
+
+		// the residual program parses back and survives serialization
+		reparsed, err := Compile(residual.Expression())
+		if err != nil {
+			t.Fatalf("%s: %v", residual.Expression(), err)
+		}
+		data, err := residual.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &Program{}
+		if err := decoded.UnmarshalJSON(data); err != nil {
+			t.Fatalf("%s: %v", residual.Expression(), err)
+		}
+		for _, p := range []*Program{residual, reparsed, decoded} {
+			result, err := p.Run(Variables{"y": 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !(math.IsNaN(test.value) && math.IsNaN(result) || result == test.value) {
+				t.Errorf("%s: expected %v, got %v", p.Expression(), test.value, result)
+			}
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		expression  string
+		name        string
+		replacement string
+		expected    string
+	}{
+		{"gross * 2", "gross", "net + tax", "(net + tax) * 2"},
+		{"x ^ 2 + x", "x", "-y", "(-y) ^ 2 + -y"},
+		{"sin(a) / a", "a", "b * c", "sin(b * c) / (b * c)"},
+		{"x + 1", "y", "2", "x + 1"},
+	}
+
+	for _, test := range tests {
+		result, err := Substitute(test.expression, test.name, test.replacement)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.expression, test.expected, result)
+		}
+	}
+
+	if _, err := Substitute("x + 1", "x", "2 +"); err == nil {
+		t.Errorf("expected an error for an invalid replacement")
+	}
+}
@@ -0,0 +1,174 @@
+package nparser
+
+import "math"
+
+// Backend selects how a Program is evaluated
+type Backend int
+
+const (
+	// BytecodeBackend evaluates a Program with the bytecode virtual machine
+	BytecodeBackend Backend = iota
+
+	// ClosureBackend evaluates a Program with a tree of Go closures
+	ClosureBackend
+)
+
+// closure is an expression compiled to nested Go closures
+type closure func(*Env) float64
+
+// compileClosure compiles a folded expression tree to closures. Function arguments
+// are gathered in the environment's stack starting at depth, which mirrors the
+// stack layout of the bytecode so that the stack size computed for it suffices.
+func (p *Program) compileClosure(node *Node, depth int) (closure, error) {
+	c, err := p.compileNode(node, depth)
+	if err != nil || node.Kind == NumberNode || node.Kind == VariableNode {
+		return c, err
+	}
+	if p.policy.policy == ErrorPolicy {
+		// under ErrorPolicy, every operator and function checks its result
+		name := node.Name
+		checked := c
+		c = func(env *Env) float64 {
+			result := checked(env)
+			if err := checkFinite(name, result, math.NaN()); err != nil {
+				return env.fail(err)
+			}
+			return result
+		}
+	}
+	// every node is evaluated once, so checking the context at every interval of the nodes
+	// compiled checks it at every interval of the steps of an evaluation
+	p.closureNodes++
+	if p.closureNodes%contextCheckInterval == 0 {
+		interrupted := c
+		c = func(env *Env) float64 {
+			if env.ctx != nil {
+				if err := env.ctx.Err(); err != nil {
+					return env.fail(err)
+				}
+			}
+			return interrupted(env)
+		}
+	}
+	return c, nil
+}
+
+// compileNode compiles a node of a folded expression tree to a closure
+func (p *Program) compileNode(node *Node, depth int) (closure, error) {
+	switch node.Kind {
+	case NumberNode:
+		value := node.Value
+		return func(*Env) float64 { return value }, nil
+
+	case VariableNode:
+		slot, ok := p.slots[node.Name]
+		if !ok {
+			return nil, ErrUndefinedVariable{Variable: node.Name}
+		}
+		return func(env *Env) float64 { return env.slots[slot] }, nil
+
+	case OperatorNode:
+		a, err := p.compileClosure(node.Args[0], depth)
+		if err != nil {
+			return nil, err
+		}
+		if len(node.Args) == 1 {
+			switch node.Name {
+			case UMINUS:
+				return func(env *Env) float64 { return -a(env) }, nil
+			case NOT:
+				return func(env *Env) float64 {
+					x, err := toInteger(NOT, a(env))
+					if err != nil {
+						return env.fail(err)
+					}
+					return float64(^x)
+				}, nil
+			}
+			return nil, ErrUnsupportedOperator{Operator: node.Name}
+		}
+		b, err := p.compileClosure(node.Args[1], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		switch node.Name {
+		case PLUS:
+			return func(env *Env) float64 { return a(env) + b(env) }, nil
+		case MINUS:
+			return func(env *Env) float64 { return a(env) - b(env) }, nil
+		case MUL:
+			return func(env *Env) float64 { return a(env) * b(env) }, nil
+		case DIV:
+			if p.policy.policy == ErrorPolicy {
+				return func(env *Env) float64 {
+					x, divisor := a(env), b(env)
+					if divisor == 0 {
+						return env.fail(ErrDivisionByZero{})
+					}
+					return x / divisor
+				}, nil
+			}
+			return func(env *Env) float64 { return a(env) / b(env) }, nil
+		case POW:
+			if p.limits.MaxExponent > 0 {
+				return func(env *Env) float64 {
+					base, exponent := a(env), b(env)
+					if err := p.limits.checkExponent(exponent); err != nil {
+						return env.fail(err)
+					}
+					return math.Pow(base, exponent)
+				}, nil
+			}
+			return func(env *Env) float64 { return math.Pow(a(env), b(env)) }, nil
+		case AND, OR, XOR, SHL, SHR:
+			operator := Token(node.Name)
+			return func(env *Env) float64 {
+				res, err := applyIntegerOperator(operator, a(env), b(env))
+				if err != nil {
+					return env.fail(err)
+				}
+				return res
+			}, nil
+		}
+		return nil, ErrUnsupportedOperator{Operator: node.Name}
+
+	case CallNode:
+		desc, ok := functionList[node.Name]
+		if !ok {
+			return nil, ErrUnknownFunction{Function: node.Name}
+		}
+		args := make([]closure, 0, len(node.Args)+len(desc.defaults))
+		for i, arg := range node.Args {
+			c, err := p.compileClosure(arg, depth+i)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, c)
+		}
+		for _, value := range desc.defaults[min(len(node.Args)-desc.arity, len(desc.defaults)):] {
+			args = append(args, func(*Env) float64 { return value })
+		}
+		argc := len(args)
+		fn, randFn := desc.fn, desc.randFn
+		return func(env *Env) float64 {
+			values := env.stack[depth : depth+argc]
+			for i, arg := range args {
+				values[i] = arg(env)
+			}
+			if randFn != nil {
+				return randFn(env.random(), values...)
+			}
+			return fn(values...)
+		}, nil
+	}
+
+	return nil, ErrEmptyStack{}
+}
+
+// fail records the first error of an evaluation by closures
+func (env *Env) fail(err error) float64 {
+	if env.err == nil {
+		env.err = err
+	}
+	return math.NaN()
+}
@@ -0,0 +1,422 @@
+package nparser
+
+import (
+	"bytes"
+	"go/format"
+	"go/token"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// GoOptions configures the Go source generated for a Program
+type GoOptions struct {
+	// Package is the name of the generated package, main by default
+	Package string
+	// Function is the name of the generated function
+	Function string
+	// Parameters orders the parameters of the generated function, each variable once. By default
+	// they follow the order in which the variables first appear in the expression.
+	Parameters []string
+}
+
+// goFunction is the Go translation of a built-in function
+type goFunction struct {
+	// imports are the packages the translation refers to
+	imports []string
+	// helpers are the helper functions the translation calls
+	helpers []string
+	emit    func(args []string) string
+}
+
+// goCall returns a translation calling the Go function with the arguments as written
+func goCall(pkg, name string) goFunction {
+	return goFunction{
+		imports: []string{pkg},
+		emit: func(args []string) string {
+			return name + "(" + strings.Join(args, ", ") + ")"
+		},
+	}
+}
+
+// goReciprocal returns a translation of the reciprocal of a math function
+func goReciprocal(name string) goFunction {
+	return goFunction{
+		imports: []string{"math"},
+		emit: func(args []string) string {
+			return "(1.0 / math." + name + "(" + args[0] + "))"
+		},
+	}
+}
+
+// goFold returns a translation folding a two-argument math function over all arguments
+func goFold(name string) goFunction {
+	return goFunction{
+		imports: []string{"math"},
+		emit: func(args []string) string {
+			result := args[0]
+			for _, arg := range args[1:] {
+				result = "math." + name + "(" + result + ", " + arg + ")"
+			}
+			return result
+		},
+	}
+}
+
+// goFunctions holds the Go translations of the functions in functionList
+var goFunctions = map[string]goFunction{
+	"sin":   goCall("math", "math.Sin"),
+	"cos":   goCall("math", "math.Cos"),
+	"tan":   goCall("math", "math.Tan"),
+	"cosec": goReciprocal("Sin"),
+	"sec":   goReciprocal("Cos"),
+	"cot":   goReciprocal("Tan"),
+	"log":   goCall("math", "math.Log"),
+	"log10": goCall("math", "math.Log10"),
+	"log2":  goCall("math", "math.Log2"),
+	"sqrt":  goCall("math", "math.Sqrt"),
+	"max":   goFold("Max"),
+	"min":   goFold("Min"),
+	"rand":  goCall("math/rand", "rand.Float64"),
+	"randint": {
+		imports: []string{"math", "math/rand"},
+		helpers: []string{"randInt"},
+		emit: func(args []string) string {
+			return "numeroRandInt(" + args[0] + ", " + args[1] + ")"
+		},
+	},
+	"uniform": {
+		imports: []string{"math/rand"},
+		emit: func(args []string) string {
+			return "(" + args[0] + " + rand.Float64()*(" + args[1] + " - " + args[0] + "))"
+		},
+	},
+	"normal": {
+		imports: []string{"math/rand"},
+		emit: func(args []string) string {
+			return "(" + args[0] + " + rand.NormFloat64()*" + args[1] + ")"
+		},
+	},
+	"pmt":  goCall(nfinanceImport, "nfinance.Pmt"),
+	"fv":   goCall(nfinanceImport, "nfinance.Fv"),
+	"pv":   goCall(nfinanceImport, "nfinance.Pv"),
+	"nper": goCall(nfinanceImport, "nfinance.Nper"),
+	"rate": goCall(nfinanceImport, "nfinance.Rate"),
+	"npv":  goCall(nfinanceImport, "nfinance.Npv"),
+	"irr": {
+		imports: []string{nfinanceImport},
+		emit: func(args []string) string {
+			return "nfinance.Irr([]float64{" + strings.Join(args, ", ") + "}, 0.1)"
+		},
+	},
+	"irrguess": {
+		imports: []string{nfinanceImport},
+		emit: func(args []string) string {
+			return "nfinance.Irr([]float64{" + strings.Join(args[1:], ", ") + "}, " + args[0] + ")"
+		},
+	},
+}
+
+const nfinanceImport = "github.com/viveknathani/numero/nfinance"
+
+// goHelpers holds the source of the helper functions generated code may call
+var goHelpers = map[string]string{
+	"integer": `// numeroInt converts an operand of an integer operator, panicking for values that are not integers
+func numeroInt(operator string, value float64) int64 {
+	if value != math.Trunc(value) || value < math.MinInt64 || value >= -math.MinInt64 {
+		panic("non-integer operand for operator " + operator + ": " + strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return int64(value)
+}`,
+	"shift": `// numeroShift shifts an integer, panicking for counts outside [0, 63] and for left shifts that overflow
+func numeroShift(operator string, x, count int64) float64 {
+	if count < 0 || count >= 64 {
+		panic("shift count out of range for operator " + operator + ": " + strconv.FormatInt(count, 10))
+	}
+	if operator == ">>" {
+		return float64(x >> count)
+	}
+	if x<<count>>count != x {
+		panic("integer overflow in operator " + operator)
+	}
+	return float64(x << count)
+}`,
+	"randInt": `// numeroRandInt returns a uniformly distributed integer in [a, b], or NaN if the range is empty or too wide
+func numeroRandInt(a, b float64) float64 {
+	lo, hi := math.Ceil(a), math.Floor(b)
+	// a range with an infinite bound, or too wide to count in an int64, cannot be drawn from
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) || hi < lo || hi-lo >= 0x1p63 {
+		return math.NaN()
+	}
+	return lo + float64(rand.Int63n(int64(hi-lo)+1))
+}`,
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by numero. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{range .Imports}}{{if .}}	"{{.}}"{{end}}
+{{end}})
+{{end}}
+// {{.Function}} evaluates {{.Expression}}
+func {{.Function}}({{.Parameters}}) float64 {
+	return {{.Body}}
+}
+{{range .Helpers}}
+{{.}}
+{{end}}`))
+
+// goGenerator translates an expression tree to a Go expression
+type goGenerator struct {
+	// names maps variables to the Go identifiers of the parameters
+	names   map[string]string
+	imports map[string]bool
+	helpers map[string]bool
+}
+
+// GenerateGo emits a Go source file containing a function equivalent to the
+// program, so that the expression can be compiled into a service with no parser.
+func (p *Program) GenerateGo(options GoOptions) ([]byte, error) {
+	if options.Package == "" {
+		options.Package = "main"
+	}
+	if !token.IsIdentifier(options.Function) {
+		return nil, ErrInvalidIdentifier{Name: options.Function}
+	}
+	if !token.IsIdentifier(options.Package) {
+		return nil, ErrInvalidIdentifier{Name: options.Package}
+	}
+
+	parameters := options.Parameters
+	if parameters == nil {
+		parameters = p.variables
+	}
+	g := &goGenerator{
+		names:   make(map[string]string),
+		imports: make(map[string]bool),
+		helpers: make(map[string]bool),
+	}
+	used := make(map[string]bool)
+	identifiers := make([]string, len(parameters))
+	for i, name := range parameters {
+		if !IsVariableName(name) {
+			return nil, ErrInvalidIdentifier{Name: name}
+		}
+		if _, ok := g.names[name]; ok {
+			return nil, ErrDuplicateParameter{Name: name}
+		}
+		identifier := goIdentifier(name)
+		for used[identifier] {
+			identifier += "_"
+		}
+		used[identifier] = true
+		g.names[name] = identifier
+		identifiers[i] = identifier
+	}
+	for _, name := range p.variables {
+		if _, ok := g.names[name]; !ok {
+			return nil, ErrUndefinedVariable{Variable: name}
+		}
+	}
+
+	body, err := g.expression(fold(p.root))
+	if err != nil {
+		return nil, err
+	}
+
+	signature := ""
+	if len(identifiers) > 0 {
+		signature = strings.Join(identifiers, ", ") + " float64"
+	}
+	helpers := make([]string, 0, len(g.helpers))
+	for helper := range g.helpers {
+		helpers = append(helpers, goHelpers[helper])
+	}
+	sort.Strings(helpers)
+	// standard library imports come first, in their own group
+	imports := make([]string, 0, len(g.imports))
+	for pkg := range g.imports {
+		imports = append(imports, pkg)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		iStd, jStd := !strings.Contains(imports[i], "."), !strings.Contains(imports[j], ".")
+		if iStd != jStd {
+			return iStd
+		}
+		return imports[i] < imports[j]
+	})
+	for i := 1; i < len(imports); i++ {
+		if !strings.Contains(imports[i-1], ".") && strings.Contains(imports[i], ".") {
+			imports = append(imports[:i], append([]string{""}, imports[i:]...)...)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	err = goTemplate.Execute(&buf, map[string]any{
+		"Package":    options.Package,
+		"Imports":    imports,
+		"Function":   options.Function,
+		"Expression": strings.Join(strings.Fields(string(p.expression)), " "),
+		"Parameters": signature,
+		"Body":       body,
+		"Helpers":    helpers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// expression returns the Go expression evaluating the node of a folded tree
+func (g *goGenerator) expression(node *Node) (string, error) {
+	if value, ok := g.constant(node); ok {
+		return g.number(value), nil
+	}
+
+	switch node.Kind {
+	case NumberNode:
+		return g.number(node.Value), nil
+
+	case VariableNode:
+		return g.names[node.Name], nil
+
+	case OperatorNode:
+		args := make([]string, len(node.Args))
+		for i, arg := range node.Args {
+			var err error
+			if args[i], err = g.expression(arg); err != nil {
+				return "", err
+			}
+		}
+		switch node.Name {
+		case UMINUS:
+			return "(-" + args[0] + ")", nil
+		case NOT:
+			return "float64(^" + g.integer(NOT, args[0]) + ")", nil
+		case PLUS, MINUS, MUL, DIV:
+			return "(" + args[0] + " " + node.Name + " " + args[1] + ")", nil
+		case POW:
+			g.imports["math"] = true
+			return "math.Pow(" + args[0] + ", " + args[1] + ")", nil
+		case SHL, SHR:
+			g.helpers["shift"] = true
+			return "numeroShift(" + strconv.Quote(node.Name) + ", " + g.integer(node.Name, args[0]) + ", " + g.integer(node.Name, args[1]) + ")", nil
+		case AND, OR:
+			return "float64(" + g.integer(node.Name, args[0]) + " " + node.Name + " " + g.integer(node.Name, args[1]) + ")", nil
+		case XOR:
+			return "float64(" + g.integer(XOR, args[0]) + " ^ " + g.integer(XOR, args[1]) + ")", nil
+		}
+		return "", ErrUnsupportedOperator{Operator: node.Name}
+
+	case CallNode:
+		desc, ok := functionList[node.Name]
+		translation, translated := goFunctions[node.Name]
+		if !ok || !translated {
+			return "", ErrUnknownFunction{Function: node.Name}
+		}
+		args := make([]string, 0, len(node.Args)+len(desc.defaults))
+		for _, arg := range node.Args {
+			code, err := g.expression(arg)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, code)
+		}
+		for _, value := range desc.defaults[min(len(node.Args)-desc.arity, len(desc.defaults)):] {
+			args = append(args, g.number(value))
+		}
+		for _, pkg := range translation.imports {
+			g.imports[pkg] = true
+		}
+		for _, helper := range translation.helpers {
+			g.helpers[helper] = true
+		}
+		return translation.emit(args), nil
+	}
+
+	return "", ErrEmptyStack{}
+}
+
+// constant evaluates the constant subtrees left by folding, whose value is not
+// finite. Go would reject them as constant expressions, as in 1.0 / 0.0.
+func (g *goGenerator) constant(node *Node) (float64, bool) {
+	if node.Kind != OperatorNode && node.Kind != CallNode || node.isVolatile() {
+		return 0, false
+	}
+	values := make([]float64, len(node.Args))
+	for i, arg := range node.Args {
+		if arg.Kind != NumberNode {
+			return 0, false
+		}
+		values[i] = arg.Value
+	}
+	value, err := applyNode(node, values)
+	return value, err == nil
+}
+
+// number returns a Go float64 expression for the value
+func (g *goGenerator) number(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		g.imports["math"] = true
+		return "math.NaN()"
+	case math.IsInf(value, 1):
+		g.imports["math"] = true
+		return "math.Inf(1)"
+	case math.IsInf(value, -1):
+		g.imports["math"] = true
+		return "math.Inf(-1)"
+	}
+	literal := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(literal, ".e") {
+		// keep the constant a float, so that Go does not divide it as an integer
+		literal += ".0"
+	}
+	if value < 0 {
+		return "(" + literal + ")"
+	}
+	return literal
+}
+
+// integer returns a Go int64 expression converting an operand of an integer operator
+func (g *goGenerator) integer(operator, operand string) string {
+	g.imports["math"] = true
+	g.imports["strconv"] = true
+	g.helpers["integer"] = true
+	return "numeroInt(" + strconv.Quote(operator) + ", " + operand + ")"
+}
+
+// goIdentifier turns a variable name into a Go identifier that does not shadow the generated imports
+func goIdentifier(name string) string {
+	identifier := []rune(name)
+	for i, ch := range identifier {
+		if ch != '_' && !unicode.IsLetter(ch) && !unicode.IsDigit(ch) {
+			identifier[i] = '_'
+		}
+	}
+	result := string(identifier)
+	switch {
+	case token.IsKeyword(result), goReserved[result]:
+		result += "_"
+	case strings.HasPrefix(result, "numero"):
+		result = "_" + result
+	}
+	return result
+}
+
+// goReserved holds the identifiers generated code refers to, which parameters must not shadow
+var goReserved = map[string]bool{
+	"math":     true,
+	"rand":     true,
+	"strconv":  true,
+	"nfinance": true,
+	"float64":  true,
+	"int64":    true,
+	"panic":    true,
+}
@@ -0,0 +1,172 @@
+package nparser
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	program, err := Compile("x * 2 / 4 + max(x, y) ^ 2 - pv(0.1, 5, 0, -y)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := program.GenerateGo(GoOptions{Package: "pricing", Function: "Price", Parameters: []string{"y", "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `// Code generated by numero. DO NOT EDIT.
+
+package pricing
+
+import (
+	"math"
+
+	"github.com/viveknathani/numero/nfinance"
+)
+
+// Price evaluates x * 2 / 4 + max(x, y) ^ 2 - pv(0.1, 5, 0, -y)
+func Price(y, x float64) float64 {
+	return ((((x * 2.0) / 4.0) + math.Pow(math.Max(x, y), 2.0)) - nfinance.Pv(0.1, 5.0, 0.0, (-y), 0.0))
+}
+`
+	if string(source) != expected {
+		t.Errorf("unexpected source:\n%s", source)
+	}
+}
+
+func TestGenerateGoWithIntegerOperators(t *testing.T) {
+	np := New("reg & ~mask.low")
+	np.SetIntegerMode(true)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := program.GenerateGo(GoOptions{Function: "Mask"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(source), "func Mask(reg, mask_low float64) float64") ||
+		!strings.Contains(string(source), "func numeroInt(operator string, value float64) int64") {
+		t.Errorf("unexpected source:\n%s", source)
+	}
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	program, err := Compile("x + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.GenerateGo(GoOptions{Function: "not an identifier"}); err == nil {
+		t.Error("expected an error for an invalid function name")
+	}
+	if _, err := program.GenerateGo(GoOptions{Function: "Sum", Parameters: []string{"x"}}); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+	if _, err := program.GenerateGo(GoOptions{Function: "Sum", Parameters: []string{"x", "y", "x"}}); !errors.Is(err, ErrDuplicateParameter{Name: "x"}) {
+		t.Errorf("expected ErrDuplicateParameter, got %v", err)
+	}
+	for _, name := range []string{"", "1x", "x y", "xor"} {
+		if _, err := program.GenerateGo(GoOptions{Function: "Sum", Parameters: []string{"x", "y", name}}); !errors.Is(err, ErrInvalidIdentifier{Name: name}) {
+			t.Errorf("%q: expected ErrInvalidIdentifier, got %v", name, err)
+		}
+	}
+}
+
+func TestEveryFunctionCanBeGenerated(t *testing.T) {
+	for name := range functionList {
+		if _, ok := goFunctions[name]; !ok {
+			t.Errorf("no Go translation for function %s", name)
+		}
+	}
+}
+
+func TestGeneratedGoBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go tool")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not available")
+	}
+
+	// every function, called with as many arguments as it needs, and the integer operators
+	var calls []string
+	for name, desc := range functionList {
+		args := make([]string, desc.arity)
+		for i := range args {
+			args[i] = "x"
+		}
+		calls = append(calls, name+"("+strings.Join(args, ", ")+")")
+	}
+	tests := []struct {
+		expression string
+		// main is the source of the main function calling Eval, after the package clause
+		main     string
+		expected string
+	}{
+		{
+			strings.Join(calls, " + ") + " + (y & 3 | y << 1 xor ~y >> 2)",
+			"import \"fmt\"\n\nfunc main() {\n\tEval(0.5, 2)\n\tfmt.Println(\"ok\")\n}\n",
+			"ok",
+		},
+		{
+			"randint(x, y)",
+			"import (\n\t\"fmt\"\n\t\"math\"\n)\n\nfunc main() {\n\tfmt.Println(Eval(0, math.Inf(1)), Eval(0, 1<<63), Eval(2, 2))\n}\n",
+			"NaN NaN 2",
+		},
+		{
+			"x << y",
+			"import \"fmt\"\n\nfunc main() {\n\tdefer func() { fmt.Println(recover()) }()\n\tfmt.Println(Eval(-1, 63))\n\tEval(1, 64)\n}\n",
+			"-9.223372036854776e+18\nshift count out of range for operator <<: 64",
+		},
+		{
+			"x << y",
+			"import \"fmt\"\n\nfunc main() {\n\tdefer func() { fmt.Println(recover()) }()\n\tEval(1, 63)\n}\n",
+			"integer overflow in operator <<",
+		},
+	}
+
+	for _, test := range tests {
+		np := New(test.expression)
+		np.SetIntegerMode(true)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		source, err := program.GenerateGo(GoOptions{Function: "Eval", Parameters: []string{"x", "y"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a directory of the module, for the imports of nfinance, that the go tool leaves out of ./...
+		dir, err := os.MkdirTemp(".", "_generated")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		main := "package main\n\n" + test.main
+		if err := os.WriteFile(filepath.Join(dir, "eval.go"), source, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		pkg := "./" + filepath.Base(dir)
+		if output, err := exec.Command(goTool, "vet", pkg).CombinedOutput(); err != nil {
+			t.Fatalf("%s: go vet failed: %v\n%s", test.expression, err, output)
+		}
+		output, err := exec.Command(goTool, "run", pkg).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: go run failed: %v\n%s", test.expression, err, output)
+		}
+		if strings.TrimSpace(string(output)) != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.expression, test.expected, output)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package nparser
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// columnBlock is the number of rows evaluated together, small enough for the
+// columns of the stack to stay in cache
+const columnBlock = 1024
+
+// RowError is the error of a single row of a columnar evaluation
+type RowError struct {
+	Row int
+	Err error
+}
+
+// EvalColumns evaluates the program over columns of values, one column per variable,
+// returning a column of results. Each instruction is applied to a block of rows at a
+// time, with the bytecode backend whatever the backend of the program. Rows that fail
+// are set to NaN and reported together in an ErrRows.
+func (p *Program) EvalColumns(columns map[string][]float64) ([]float64, error) {
+	return p.EvalColumnsContext(context.Background(), columns)
+}
+
+// EvalColumnsContext evaluates the program over columns of values like EvalColumns, giving
+// up with the error of the context once it is done. The context is checked before every
+// instruction applied to a block of rows.
+func (p *Program) EvalColumnsContext(ctx context.Context, columns map[string][]float64) ([]float64, error) {
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+	return p.EvalColumnsEnv(ctx, env, columns)
+}
+
+// EvalColumnsEnv evaluates the program over columns of values like EvalColumnsContext, drawing
+// the numbers of random functions from the source of the environment, as set by SetSeed or SetRand
+func (p *Program) EvalColumnsEnv(ctx context.Context, env *Env, columns map[string][]float64) ([]float64, error) {
+	inputs := make([][]float64, len(p.variables))
+	for slot, name := range p.variables {
+		column, ok := columns[name]
+		if !ok {
+			return nil, ErrUndefinedVariable{Variable: name}
+		}
+		inputs[slot] = column
+	}
+
+	// every column has the length of the first variable's, or of the first column by name
+	// without variables, including those the program does not use
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := 0
+	switch {
+	case len(inputs) > 0:
+		rows = len(inputs[0])
+	case len(names) > 0:
+		rows = len(columns[names[0]])
+	}
+	for _, name := range names {
+		if len(columns[name]) != rows {
+			return nil, ErrColumnLength{Variable: name, Length: len(columns[name]), Expected: rows}
+		}
+	}
+
+	results := make([]float64, rows)
+	block := min(rows, columnBlock)
+	stack := make([][]float64, p.stackSize)
+	for i := range stack {
+		stack[i] = make([]float64, block)
+	}
+	ev := &columnEvaluator{
+		program: p,
+		stack:   stack,
+		args:    make([]float64, p.stackSize),
+		failed:  make([]bool, block),
+		env:     env,
+	}
+
+	for start := 0; start < rows; start += block {
+		end := min(start+block, rows)
+		if err := ev.run(ctx, inputs, start, end); err != nil {
+			return nil, err
+		}
+		copy(results[start:end], stack[0][:end-start])
+	}
+
+	if len(ev.errors) > 0 {
+		sort.Slice(ev.errors, func(i, j int) bool { return ev.errors[i].Row < ev.errors[j].Row })
+		return results, ErrRows{Errors: ev.errors}
+	}
+	return results, nil
+}
+
+// columnEvaluator holds the state of a columnar evaluation
+type columnEvaluator struct {
+	program *Program
+	// stack is the stack of the virtual machine, holding a column per value
+	stack [][]float64
+	// args gathers the arguments of a call for a single row
+	args []float64
+	// failed marks the rows of the block that already failed
+	failed []bool
+	errors []RowError
+	env    *Env
+}
+
+// run evaluates the rows from start to end, leaving their results at the bottom of the stack,
+// unless the context is done
+func (ev *columnEvaluator) run(ctx context.Context, inputs [][]float64, start, end int) error {
+	n := end - start
+	stack := ev.stack
+	sp := 0
+	clear(ev.failed)
+	checked := ev.program.policy.policy == ErrorPolicy
+
+	for i := range ev.program.code {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ins := &ev.program.code[i]
+		switch ins.op {
+		case opConst:
+			top := stack[sp][:n]
+			for r := range top {
+				top[r] = ins.value
+			}
+			sp++
+		case opLoad:
+			copy(stack[sp][:n], inputs[ins.arg][start:end])
+			sp++
+		case opNeg:
+			top := stack[sp-1][:n]
+			for r := range top {
+				top[r] = -top[r]
+			}
+		case opNot:
+			top := stack[sp-1][:n]
+			for r := range top {
+				x, err := toInteger(NOT, top[r])
+				if err != nil {
+					top[r] = ev.fail(start, r, err)
+					continue
+				}
+				top[r] = float64(^x)
+			}
+		case opAdd:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			for r := range a {
+				a[r] += b[r]
+			}
+		case opSub:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			for r := range a {
+				a[r] -= b[r]
+			}
+		case opMul:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			for r := range a {
+				a[r] *= b[r]
+			}
+		case opDiv:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			for r := range a {
+				a[r] /= b[r]
+			}
+		case opPow:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			for r := range a {
+				if err := ev.program.limits.checkExponent(b[r]); err != nil {
+					a[r] = ev.fail(start, r, err)
+					continue
+				}
+				a[r] = math.Pow(a[r], b[r])
+			}
+		case opAnd, opOr, opXor, opShl, opShr:
+			sp--
+			a, b := stack[sp-1][:n], stack[sp][:n]
+			operator := operators[ins.op]
+			for r := range a {
+				res, err := applyIntegerOperator(operator, a[r], b[r])
+				if err != nil {
+					a[r] = ev.fail(start, r, err)
+					continue
+				}
+				a[r] = res
+			}
+		case opCall, opCallRand:
+			c := &ev.program.calls[ins.arg]
+			sp -= c.argc
+			args := ev.args[:c.argc]
+			for r := 0; r < n; r++ {
+				for i := range args {
+					args[i] = stack[sp+i][r]
+				}
+				if ins.op == opCallRand {
+					stack[sp][r] = c.randFn(ev.env.random(), args...)
+				} else {
+					stack[sp][r] = c.fn(args...)
+				}
+			}
+			sp++
+		}
+
+		if checked && ins.op > opLoad {
+			name := ev.program.instructionName(ins)
+			for r, result := range stack[sp-1][:n] {
+				// the divisor of a division is left just above its result
+				divisor := math.NaN()
+				if ins.op == opDiv {
+					divisor = stack[sp][r]
+				}
+				if err := checkFinite(name, result, divisor); err != nil {
+					ev.fail(start, r, err)
+				}
+			}
+		}
+	}
+
+	for r, result := range stack[0][:n] {
+		if !ev.failed[r] {
+			stack[0][r] = ev.program.policy.apply(result)
+		}
+	}
+	return nil
+}
+
+// fail records the first error of a row and returns the value of a failed row
+func (ev *columnEvaluator) fail(start, r int, err error) float64 {
+	if !ev.failed[r] {
+		ev.failed[r] = true
+		ev.errors = append(ev.errors, RowError{Row: start + r, Err: err})
+	}
+	return math.NaN()
+}
@@ -0,0 +1,172 @@
+package nparser
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvalColumnsMatchesRun(t *testing.T) {
+	for _, test := range evalCases {
+		np := New(test.expression)
+		np.SetIntegerMode(test.integerMode)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		expected, err := program.Run(test.variables)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+
+		// the same row repeated across more than one block
+		rows := columnBlock + 3
+		columns := map[string][]float64{"unused": make([]float64, rows)}
+		for name, value := range test.variables {
+			column := make([]float64, rows)
+			for i := range column {
+				column[i] = value
+			}
+			columns[name] = column
+		}
+		results, err := program.EvalColumns(columns)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if len(results) != rows {
+			t.Fatalf("%s: expected %d results, got %d", test.expression, rows, len(results))
+		}
+		for i, result := range results {
+			if result != expected {
+				t.Errorf("%s: row %d gave %v, Run gave %v", test.expression, i, result, expected)
+				break
+			}
+		}
+	}
+}
+
+func TestEvalColumnsRowErrors(t *testing.T) {
+	np := New("x & ~y")
+	np.SetIntegerMode(true)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := program.EvalColumns(map[string][]float64{
+		"x": {7, 1.5, 7, 7},
+		"y": {1, 1, 2, 0.5},
+	})
+	var rowErrors ErrRows
+	if !errors.As(err, &rowErrors) {
+		t.Fatalf("expected ErrRows, got %v", err)
+	}
+	if len(rowErrors.Errors) != 2 || rowErrors.Errors[0].Row != 1 || rowErrors.Errors[1].Row != 3 {
+		t.Errorf("expected rows 1 and 3 to fail once each, got %v", rowErrors.Errors)
+	}
+	if results[0] != 6 || !math.IsNaN(results[1]) || results[2] != 5 || !math.IsNaN(results[3]) {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+func TestEvalColumnsErrors(t *testing.T) {
+	program, err := Compile("x + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.EvalColumns(map[string][]float64{"x": {1}}); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+	if _, err := program.EvalColumns(map[string][]float64{"x": {1}, "y": {1, 2}}); err == nil {
+		t.Error("expected an error for columns of different lengths")
+	}
+
+	expected := ErrColumnLength{Variable: "z", Length: 3, Expected: 2}
+	if _, err := program.EvalColumns(map[string][]float64{"x": {1, 2}, "y": {1, 2}, "z": {1, 2, 3}}); err != expected {
+		t.Errorf("expected %v for an unused column, got %v", expected, err)
+	}
+
+	constant, err := Compile("1 + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = ErrColumnLength{Variable: "b", Length: 3, Expected: 2}
+	for i := 0; i < 10; i++ {
+		if _, err := constant.EvalColumns(map[string][]float64{"a": {1, 2}, "b": {1, 2, 3}}); err != expected {
+			t.Errorf("expected %v without variables, got %v", expected, err)
+		}
+	}
+	results, err := constant.EvalColumns(map[string][]float64{"a": {1, 2}, "b": {3, 4}})
+	if err != nil || len(results) != 2 || results[1] != 3 {
+		t.Errorf("expected two rows of 3, got %v and %v", results, err)
+	}
+}
+
+func TestEvalColumnsContext(t *testing.T) {
+	program, err := Compile("x * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := map[string][]float64{"x": make([]float64, 3*columnBlock)}
+
+	// cancelled after the instructions of the first block
+	ctx := &expiringContext{Context: context.Background(), limit: 3}
+	if _, err := program.EvalColumnsContext(ctx, columns); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	results, err := program.EvalColumnsContext(context.Background(), columns)
+	if err != nil || len(results) != 3*columnBlock {
+		t.Errorf("expected %d results, got %d (%v)", 3*columnBlock, len(results), err)
+	}
+}
+
+func TestEvalColumnsEnv(t *testing.T) {
+	program, err := Compile("x * uniform(0, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := map[string][]float64{"x": {1, 2, 3, 4}}
+
+	env := program.NewEnv()
+	env.SetSeed(7)
+	results, err := program.EvalColumnsEnv(context.Background(), env, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the rows draw from the source in order, like evaluations one row at a time
+	env.SetSeed(7)
+	for i, x := range columns["x"] {
+		if err := program.SetVariables(env, Variables{"x": x}); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := program.Eval(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[i] != expected {
+			t.Errorf("row %d: expected %v for the same seed, got %v", i, expected, results[i])
+		}
+	}
+}
+
+func BenchmarkEvalColumns(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	columns := map[string][]float64{}
+	for name, value := range benchmarkVariables {
+		column := make([]float64, 100000)
+		for i := range column {
+			column[i] = value + float64(i%10)
+		}
+		columns[name] = column
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.EvalColumns(columns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package nparser
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+// equivalenceProbes is the number of random assignments of the variables at which two programs are compared
+const equivalenceProbes = 256
+
+// equivalenceTolerance is the relative difference under which two results are considered equal
+const equivalenceTolerance = 1e-9
+
+// equivalenceSeed seeds the assignments of the variables, so that the same programs always get the same verdict
+const equivalenceSeed = 1
+
+// Equivalence is the verdict on whether two expressions are equivalent
+type Equivalence struct {
+	Equivalent bool
+	// Proven is true when the expressions simplify to the same expression
+	Proven bool
+	// Confidence is the confidence in the verdict, between 0 and 1. It is 1 when the verdict
+	// was proven or a counterexample was found, and otherwise grows with the number of probes
+	// at which the expressions are both defined.
+	Confidence float64
+	// Counterexample is an assignment of the variables at which the expressions differ
+	Counterexample Variables
+}
+
+// Equivalent checks if two expressions are mathematically equivalent, by simplifying them
+// and else comparing their results at random assignments of their variables
+func Equivalent(a, b string) (Equivalence, error) {
+	p, err := Compile(a)
+	if err != nil {
+		return Equivalence{}, err
+	}
+	q, err := Compile(b)
+	if err != nil {
+		return Equivalence{}, err
+	}
+	return p.Equivalent(q), nil
+}
+
+// Equivalent checks if the program is mathematically equivalent to another one, by simplifying
+// their trees and else comparing their results at random assignments of their variables. Two
+// results are equal when they are within a relative tolerance, or when both fail or are NaN.
+func (p *Program) Equivalent(q *Program) Equivalence {
+	if canonical(fold(p.root)).String() == canonical(fold(q.root)).String() {
+		return Equivalence{Equivalent: true, Proven: true, Confidence: 1}
+	}
+
+	names := append(append([]string{}, p.variables...), q.variables...)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	r := rand.New(rand.NewSource(equivalenceSeed))
+	pEnv, qEnv := p.NewEnv(), q.NewEnv()
+	variables := make(Variables, len(names))
+	defined := 0
+	for i := range equivalenceProbes {
+		for _, name := range names {
+			variables[name] = probeValue(r, i)
+		}
+		// random functions draw the same numbers in both programs
+		seed := r.Int63()
+		pEnv.SetSeed(seed)
+		qEnv.SetSeed(seed)
+
+		p.SetVariables(pEnv, variables)
+		q.SetVariables(qEnv, variables)
+		pResult, pErr := p.Eval(pEnv)
+		qResult, qErr := q.Eval(qEnv)
+		pDefined := pErr == nil && !math.IsNaN(pResult)
+		qDefined := qErr == nil && !math.IsNaN(qResult)
+		if pDefined != qDefined || pDefined && !approximatelyEqual(pResult, qResult) {
+			return Equivalence{Confidence: 1, Counterexample: variables}
+		}
+		if pDefined {
+			defined++
+		}
+	}
+
+	// a single probe is enough for expressions without variables
+	if len(names) == 0 && defined > 0 {
+		return Equivalence{Equivalent: true, Confidence: 1}
+	}
+	return Equivalence{Equivalent: true, Confidence: float64(defined) / float64(defined+1)}
+}
+
+// probeValue returns a random value for a variable in the i-th probe, alternating small integers,
+// which make readable counterexamples and suit the integer operators, with fractions and magnitudes
+// both small and large
+func probeValue(r *rand.Rand, i int) float64 {
+	switch i % 4 {
+	case 0:
+		return float64(r.Intn(21) - 10)
+	case 1:
+		return r.Float64()*2 - 1
+	case 2:
+		return r.Float64() * 10
+	}
+	value := math.Pow(10, r.Float64()*6-3)
+	if r.Intn(2) == 0 {
+		return -value
+	}
+	return value
+}
+
+// approximatelyEqual checks if two results are equal within the relative tolerance
+func approximatelyEqual(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= equivalenceTolerance*max(1, math.Abs(a), math.Abs(b))
+}
+
+// canonical returns a simplified tree in which subtractions and divisions are written as sums
+// and products, which are flattened with their operands sorted and their constants combined,
+// so that trees differing only in the order of their operands compare equal
+func canonical(node *Node) *Node {
+	switch node.Kind {
+	case NumberNode, VariableNode:
+		return node
+	case CallNode:
+		args := make([]*Node, len(node.Args))
+		for i, arg := range node.Args {
+			args[i] = canonical(arg)
+		}
+		if node.Name == "max" || node.Name == "min" {
+			sortNodes(args)
+		}
+		return &Node{Kind: CallNode, Name: node.Name, Args: args}
+	}
+
+	switch node.Name {
+	case UMINUS:
+		return canonical(operatorNode(MUL, &Node{Kind: NumberNode, Value: -1}, node.Args[0]))
+	case MINUS:
+		return canonical(operatorNode(PLUS, node.Args[0], operatorNode(UMINUS, node.Args[1])))
+	case DIV:
+		return canonical(operatorNode(MUL, node.Args[0], operatorNode(POW, node.Args[1], &Node{Kind: NumberNode, Value: -1})))
+	case PLUS:
+		return combine(PLUS, node, 0, func(a, b float64) float64 { return a + b })
+	case MUL:
+		return combine(MUL, node, 1, func(a, b float64) float64 { return a * b })
+	}
+
+	args := make([]*Node, len(node.Args))
+	for i, arg := range node.Args {
+		args[i] = canonical(arg)
+	}
+	return &Node{Kind: OperatorNode, Name: node.Name, Args: args}
+}
+
+// combine flattens a sum or a product into its sorted operands, combining the constants into one,
+// dropped if it is the identity of the operator
+func combine(operator string, node *Node, identity float64, apply func(a, b float64) float64) *Node {
+	var operands []*Node
+	constant := identity
+	var flatten func(node *Node)
+	flatten = func(node *Node) {
+		switch {
+		case node.Kind == OperatorNode && node.Name == operator:
+			for _, arg := range node.Args {
+				flatten(arg)
+			}
+		case node.Kind == NumberNode:
+			constant = apply(constant, node.Value)
+		default:
+			operands = append(operands, node)
+		}
+	}
+	for _, arg := range node.Args {
+		flatten(canonical(arg))
+	}
+
+	sortNodes(operands)
+	if constant != identity || len(operands) == 0 {
+		operands = append([]*Node{{Kind: NumberNode, Value: constant}}, operands...)
+	}
+	combined := operands[0]
+	for _, operand := range operands[1:] {
+		combined = operatorNode(operator, combined, operand)
+	}
+	return combined
+}
+
+// operatorNode creates a node applying an operator to its operands
+func operatorNode(operator string, args ...*Node) *Node {
+	return &Node{Kind: OperatorNode, Name: operator, Args: args}
+}
+
+// sortNodes sorts trees by their expressions
+func sortNodes(nodes []*Node) {
+	slices.SortFunc(nodes, func(a, b *Node) int {
+		return strings.Compare(a.String(), b.String())
+	})
+}
@@ -0,0 +1,48 @@
+package nparser
+
+import (
+	"testing"
+)
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		equivalent bool
+		proven     bool
+	}{
+		{"x + y", "y + x", true, true},
+		{"a * (b * c)", "c * b * a", true, true},
+		{"x - y", "-y + x", true, true},
+		{"x / 2", "0.5 * x", true, false},
+		{"x / y", "x * y ^ -1", true, true},
+		{"max(a, b) + 1 + 2", "3 + max(b, a)", true, true},
+		{"(x + 1) ^ 2", "x ^ 2 + 2 * x + 1", true, false},
+		{"sin(x) ^ 2 + cos(x) ^ 2", "1", true, false},
+		{"log(x * y)", "log(x) + log(y)", false, false},
+		{"x ^ 2", "x * x * x", false, false},
+		{"2 * 3", "6", true, true},
+		{"x + 1", "x + 1.0000001", false, false},
+	}
+
+	for _, test := range tests {
+		result, err := Equivalent(test.a, test.b)
+		if err != nil {
+			t.Fatalf("%s, %s: %v", test.a, test.b, err)
+		}
+		if result.Equivalent != test.equivalent || result.Proven != test.proven {
+			t.Errorf("%s, %s: expected equivalent %v (proven %v), got %+v", test.a, test.b, test.equivalent, test.proven, result)
+		}
+		if !result.Equivalent {
+			p, _ := Compile(test.a)
+			q, _ := Compile(test.b)
+			a, _ := p.Run(result.Counterexample)
+			b, _ := q.Run(result.Counterexample)
+			if approximatelyEqual(a, b) {
+				t.Errorf("%s, %s: counterexample %v gives %v for both", test.a, test.b, result.Counterexample, a)
+			}
+		}
+		if result.Equivalent && !result.Proven && result.Confidence < 0.99 {
+			t.Errorf("%s, %s: expected a high confidence, got %v", test.a, test.b, result.Confidence)
+		}
+	}
+}
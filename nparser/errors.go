@@ -1,8 +1,10 @@
 package nparser
 
+import "strconv"
+
 // ErrUnexpectedChar represents an error when an unexpected character is encountered
 type ErrUnexpectedChar struct {
-	Char byte
+	Char rune
 }
 
 func (e ErrUnexpectedChar) Error() string {
@@ -39,6 +41,25 @@ func (e ErrNotEnoughOperandsForFunction) Error() string {
 	return "not enough operands for function: " + e.Function
 }
 
+// ErrWrongArgumentCount represents an error when a function is called with an unsupported number of arguments
+type ErrWrongArgumentCount struct {
+	Function string
+	Count    int
+}
+
+func (e ErrWrongArgumentCount) Error() string {
+	return "wrong number of arguments for function " + e.Function + ": " + strconv.Itoa(e.Count)
+}
+
+// ErrUnknownFunction represents an error when a function that does not exist is called
+type ErrUnknownFunction struct {
+	Function string
+}
+
+func (e ErrUnknownFunction) Error() string {
+	return "unknown function: " + e.Function
+}
+
 // ErrNotEnoughOperands represents an error when an expression has insufficient operands
 type ErrNotEnoughOperands struct{}
 
@@ -46,6 +67,13 @@ func (e ErrNotEnoughOperands) Error() string {
 	return "invalid expression: not enough operands"
 }
 
+// ErrTooManyOperands represents an error when an expression has operands that no operator consumes
+type ErrTooManyOperands struct{}
+
+func (e ErrTooManyOperands) Error() string {
+	return "invalid expression: too many operands"
+}
+
 // ErrUnsupportedOperator represents an error when an unsupported operator is encountered
 type ErrUnsupportedOperator struct {
 	Operator string
@@ -55,6 +83,52 @@ func (e ErrUnsupportedOperator) Error() string {
 	return "unsupported operator: " + e.Operator
 }
 
+// ErrIntegerModeRequired represents an error when an integer operator is used outside integer mode
+type ErrIntegerModeRequired struct {
+	Operator string
+}
+
+func (e ErrIntegerModeRequired) Error() string {
+	return "operator requires integer mode: " + e.Operator
+}
+
+// ErrNonIntegerOperand represents an error when a value that is not an integer reaches an integer operator
+type ErrNonIntegerOperand struct {
+	Operator string
+	Value    float64
+}
+
+func (e ErrNonIntegerOperand) Error() string {
+	return "non-integer operand for operator " + e.Operator + ": " + strconv.FormatFloat(e.Value, 'g', -1, 64)
+}
+
+// ErrNegativeShift represents an error when a shift operator is given a negative count
+type ErrNegativeShift struct {
+	Count int64
+}
+
+func (e ErrNegativeShift) Error() string {
+	return "negative shift count: " + strconv.FormatInt(e.Count, 10)
+}
+
+// ErrShiftOutOfRange represents an error when a shift operator is given a count of 64 or more
+type ErrShiftOutOfRange struct {
+	Count int64
+}
+
+func (e ErrShiftOutOfRange) Error() string {
+	return "shift count out of range: " + strconv.FormatInt(e.Count, 10)
+}
+
+// ErrIntegerOverflow represents an error when the result of an integer operator does not fit in 64 bits
+type ErrIntegerOverflow struct {
+	Operator string
+}
+
+func (e ErrIntegerOverflow) Error() string {
+	return "integer overflow in operator " + e.Operator
+}
+
 // ErrUndefinedVariable represents an error when an undefined variable is referenced
 type ErrUndefinedVariable struct {
 	Variable string
@@ -70,3 +144,184 @@ type ErrEmptyStack struct{}
 func (e ErrEmptyStack) Error() string {
 	return "invalid expression: empty stack at the end"
 }
+
+// ErrInvalidIdentifier represents an error when a name given for generated code is not a valid Go identifier
+type ErrInvalidIdentifier struct {
+	Name string
+}
+
+func (e ErrInvalidIdentifier) Error() string {
+	return "invalid identifier: " + e.Name
+}
+
+// ErrDuplicateParameter represents an error when a variable is given twice as a parameter of generated code
+type ErrDuplicateParameter struct {
+	Name string
+}
+
+func (e ErrDuplicateParameter) Error() string {
+	return "duplicate parameter: " + e.Name
+}
+
+// ErrSlotCount represents an error when the number of values given by slot does not match the variables of a program
+type ErrSlotCount struct {
+	Expected int
+	Got      int
+}
+
+func (e ErrSlotCount) Error() string {
+	return "expected " + strconv.Itoa(e.Expected) + " variable values, got " + strconv.Itoa(e.Got)
+}
+
+// ErrColumnLength represents an error when the columns of a columnar evaluation differ in length
+type ErrColumnLength struct {
+	Variable string
+	Length   int
+	Expected int
+}
+
+func (e ErrColumnLength) Error() string {
+	return "column " + e.Variable + " has " + strconv.Itoa(e.Length) + " rows, expected " + strconv.Itoa(e.Expected)
+}
+
+// ErrRows represents the errors of the rows that failed in an evaluation of many rows
+type ErrRows struct {
+	Errors []RowError
+}
+
+func (e ErrRows) Error() string {
+	first := e.Errors[0]
+	message := "row " + strconv.Itoa(first.Row) + ": " + first.Err.Error()
+	if len(e.Errors) > 1 {
+		message += " (and " + strconv.Itoa(len(e.Errors)-1) + " more rows failed)"
+	}
+	return message
+}
+
+// ErrLimitExceeded represents an error when an expression exceeds one of the limits set on it
+type ErrLimitExceeded struct {
+	Limit string
+	Max   float64
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return "exceeded the limit of " + strconv.FormatFloat(e.Max, 'g', -1, 64) + " for " + e.Limit
+}
+
+// ErrDivisionByZero represents an error when dividing by zero
+type ErrDivisionByZero struct{}
+
+func (e ErrDivisionByZero) Error() string {
+	return "division by zero"
+}
+
+// ErrDomain represents an error when an operator or function is applied outside of its domain
+type ErrDomain struct {
+	Function string
+}
+
+func (e ErrDomain) Error() string {
+	return "argument outside of the domain of " + e.Function
+}
+
+// ErrOverflow represents an error when an operator or function gives an infinite result
+type ErrOverflow struct {
+	Function string
+}
+
+func (e ErrOverflow) Error() string {
+	return "infinite result from " + e.Function
+}
+
+// ErrLaTeX represents an error when an expression written in LaTeX cannot be parsed
+type ErrLaTeX struct {
+	// Position is the byte offset of the offending command or symbol
+	Position int
+	Command  string
+	Reason   string
+}
+
+func (e ErrLaTeX) Error() string {
+	return "latex: " + e.Reason + " " + e.Command + " at position " + strconv.Itoa(e.Position)
+}
+
+// ErrUnsupportedVersion represents an error when loading a program serialized in an unknown version
+type ErrUnsupportedVersion struct {
+	Version int
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return "unsupported serialization version " + strconv.Itoa(e.Version)
+}
+
+// ErrInvalidProgram represents an error when loading a serialized program that is malformed
+type ErrInvalidProgram struct {
+	Reason string
+}
+
+func (e ErrInvalidProgram) Error() string {
+	return "invalid program: " + e.Reason
+}
+
+// ErrSampleCount represents an error when sampling a function at fewer than two points
+type ErrSampleCount struct {
+	Count int
+}
+
+func (e ErrSampleCount) Error() string {
+	return "cannot sample a function at " + strconv.Itoa(e.Count) + " points, at least 2 are needed"
+}
+
+// ErrNotDifferentiable represents an error when differentiating an operator or function that has no derivative
+type ErrNotDifferentiable struct {
+	Function string
+}
+
+func (e ErrNotDifferentiable) Error() string {
+	return "cannot differentiate " + e.Function
+}
+
+// ErrTaylorOrder represents an error when computing a Taylor polynomial of a negative order
+type ErrTaylorOrder struct {
+	Order int
+}
+
+func (e ErrTaylorOrder) Error() string {
+	return "invalid order for a Taylor polynomial: " + strconv.Itoa(e.Order)
+}
+
+// ErrEquation represents an error when an equation is malformed or not of the expected form
+type ErrEquation struct {
+	Reason string
+}
+
+func (e ErrEquation) Error() string {
+	return "invalid equation: " + e.Reason
+}
+
+// ErrNotPolynomial represents an error when solving an equation that is not polynomial in its variable
+type ErrNotPolynomial struct {
+	Variable string
+}
+
+func (e ErrNotPolynomial) Error() string {
+	return "the equation is not polynomial in " + e.Variable
+}
+
+// ErrNotLinear represents an error when solving a system with an equation that is not linear
+type ErrNotLinear struct {
+	Equation string
+}
+
+func (e ErrNotLinear) Error() string {
+	return "the equation is not linear: " + e.Equation
+}
+
+// ErrNoUniqueSolution represents an error when a system of equations does not have a single solution
+type ErrNoUniqueSolution struct {
+	Reason string
+}
+
+func (e ErrNoUniqueSolution) Error() string {
+	return "no unique solution: " + e.Reason
+}
@@ -0,0 +1,102 @@
+package nparser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// atomPrecedence is the precedence of numbers, variables and calls, which never need parentheses
+const atomPrecedence = 100
+
+// Format parses an expression and formats it canonically
+func Format(expression string) (string, error) {
+	return New(expression).Format()
+}
+
+// Format parses the expression and formats it canonically: with a single space around
+// binary operators, the canonical names of functions, and only the parentheses needed
+// to parse it back to the same tree
+func (np *Nparser) Format() (string, error) {
+	root, err := np.Parse()
+	if err != nil {
+		return "", err
+	}
+	return root.String(), nil
+}
+
+// String formats the tree as an expression, with only the parentheses it needs
+func (node *Node) String() string {
+	var b strings.Builder
+	node.format(&b)
+	return b.String()
+}
+
+// format writes the expression of the tree
+func (node *Node) format(b *strings.Builder) {
+	switch node.Kind {
+	case NumberNode:
+		b.WriteString(formatNumber(node.Value))
+	case VariableNode:
+		b.WriteString(node.Name)
+	case CallNode:
+		b.WriteString(node.Name)
+		b.WriteString(LPAREN)
+		for i, arg := range node.Args {
+			if i > 0 {
+				b.WriteString(COMMA + " ")
+			}
+			arg.format(b)
+		}
+		b.WriteString(RPAREN)
+	case OperatorNode:
+		if len(node.Args) == 1 {
+			if node.Name == UMINUS {
+				b.WriteString(MINUS)
+			} else {
+				b.WriteString(node.Name)
+			}
+			node.Args[0].formatOperand(b, node.Args[0].precedence() < node.precedence())
+			return
+		}
+		a, c := node.Args[0], node.Args[1]
+		p := node.precedence()
+		leftAssociative := isLeftAssociative[Operator(node.Name)]
+		a.formatOperand(b, a.precedence() < p || a.precedence() == p && !leftAssociative)
+		b.WriteString(" " + node.Name + " ")
+		// a prefix operator on the right cannot be mistaken for an operand of anything else
+		c.formatOperand(b, !c.isPrefix() && (c.precedence() < p || c.precedence() == p && leftAssociative))
+	}
+}
+
+// formatOperand writes the expression of an operand, in parentheses if needed
+func (node *Node) formatOperand(b *strings.Builder, parenthesize bool) {
+	if parenthesize {
+		b.WriteString(LPAREN)
+	}
+	node.format(b)
+	if parenthesize {
+		b.WriteString(RPAREN)
+	}
+}
+
+// precedence returns the precedence of the operator at the root of the tree
+func (node *Node) precedence() int {
+	switch {
+	case node.isPrefix():
+		return precedence[UMINUS]
+	case node.Kind == OperatorNode:
+		return precedence[Operator(node.Name)]
+	}
+	return atomPrecedence
+}
+
+// isPrefix checks if the tree is written with a prefix operator, negative numbers included
+func (node *Node) isPrefix() bool {
+	return node.Kind == OperatorNode && len(node.Args) == 1 ||
+		node.Kind == NumberNode && node.Value < 0
+}
+
+// formatNumber formats a number without an exponent, which expressions cannot contain
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,80 @@
+package nparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"2+2", "2 + 2"},
+		{"((x))", "x"},
+		{"(1 - 2) - 3", "1 - 2 - 3"},
+		{"1 - (2 - 3)", "1 - (2 - 3)"},
+		{"1 + (2 + 3)", "1 + (2 + 3)"},
+		{"(2 * 3) + (4 / x)", "2 * 3 + 4 / x"},
+		{"(2 + 3) * 4", "(2 + 3) * 4"},
+		{"2 ^ (3 ^ 2)", "2 ^ 3 ^ 2"},
+		{"(2 ^ 3) ^ 2", "(2 ^ 3) ^ 2"},
+		{"-(2 ^ 2)", "-2 ^ 2"},
+		{"(-2) ^ 2", "(-2) ^ 2"},
+		{"2 ^ (-x)", "2 ^ -x"},
+		{"-(x + 1)", "-(x + 1)"},
+		{"x * (-y)", "x * -y"},
+		{"SIN( x )+Ln(y)", "sin(x) + log(y)"},
+		{"max(1,2 ,  3)", "max(1, 2, 3)"},
+		{"√16 × x²", "sqrt(16) * x ^ 2"},
+		{"0.50 + 007", "0.5 + 7"},
+		{"pmt(rate,10,1000)", "pmt(rate, 10, 1000)"},
+	}
+
+	for _, test := range tests {
+		formatted, err := Format(test.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if formatted != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.expression, test.expected, formatted)
+		}
+	}
+}
+
+func TestFormatIntegerOperators(t *testing.T) {
+	np := New("(reg&~(1<<bit))|(1 xor x)")
+	np.SetIntegerMode(true)
+	formatted, err := np.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "reg & ~(1 << bit) | 1 xor x"; formatted != expected {
+		t.Errorf("expected %q, got %q", expected, formatted)
+	}
+}
+
+func TestFormatRoundTrips(t *testing.T) {
+	for _, test := range evalCases {
+		np := New(test.expression)
+		np.SetIntegerMode(test.integerMode)
+		tree, err := np.Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+
+		formatted := tree.String()
+		np = New(formatted)
+		np.SetIntegerMode(test.integerMode)
+		again, err := np.Parse()
+		if err != nil {
+			t.Fatalf("%s: formatted as %q, which fails to parse: %v", test.expression, formatted, err)
+		}
+		if !reflect.DeepEqual(tree, again) {
+			t.Errorf("%s: formatted as %q, which parses to another tree", test.expression, formatted)
+		}
+		if again.String() != formatted {
+			t.Errorf("%s: formatting is not stable, %q then %q", test.expression, formatted, again.String())
+		}
+	}
+}
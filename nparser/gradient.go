@@ -0,0 +1,329 @@
+package nparser
+
+import (
+	"math"
+	"math/rand"
+)
+
+// derivatives holds the derivatives of the functions of a single argument
+var derivatives = map[string]func(x float64) float64{
+	"sin": math.Cos,
+	"cos": func(x float64) float64 { return -math.Sin(x) },
+	"tan": func(x float64) float64 {
+		tan := math.Tan(x)
+		return 1 + tan*tan
+	},
+	"cosec": func(x float64) float64 {
+		sin, cos := math.Sincos(x)
+		return -cos / (sin * sin)
+	},
+	"sec": func(x float64) float64 {
+		sin, cos := math.Sincos(x)
+		return sin / (cos * cos)
+	},
+	"cot": func(x float64) float64 {
+		sin := math.Sin(x)
+		return -1 / (sin * sin)
+	},
+	"log":   func(x float64) float64 { return 1 / x },
+	"log10": func(x float64) float64 { return 1 / (x * math.Ln10) },
+	"log2":  func(x float64) float64 { return 1 / (x * math.Ln2) },
+	"sqrt":  func(x float64) float64 { return 0.5 / math.Sqrt(x) },
+}
+
+// gradientState holds the buffers of gradient evaluations in an Env
+type gradientState struct {
+	// tangents holds the partial derivatives of every value of the stack, a row of every variable per value
+	tangents []float64
+	// row holds the partial derivatives of a result while it is computed
+	row []float64
+	// partials holds the partial derivatives of a function with respect to its arguments
+	partials []float64
+	// replay draws the numbers of a random function again, while it is differentiated
+	replay *rand.Rand
+	source *replaySource
+}
+
+// replaySource is a random source recording the numbers drawn from another one, to draw them again
+type replaySource struct {
+	src       *rand.Rand
+	drawn     []uint64
+	next      int
+	replaying bool
+	// extra draws the numbers past the recorded ones, as for other arguments of a function drawing
+	// until a number fits
+	extra rand.Source64
+}
+
+// record starts recording the numbers drawn from a source
+func (s *replaySource) record(src *rand.Rand) {
+	s.src = src
+	s.drawn = s.drawn[:0]
+	s.replaying = false
+}
+
+// rewind starts drawing the recorded numbers again
+func (s *replaySource) rewind() {
+	s.next = 0
+	s.replaying = true
+	s.extra.Seed(0)
+}
+
+// Uint64 implements rand.Source64
+func (s *replaySource) Uint64() uint64 {
+	if !s.replaying {
+		value := s.src.Uint64()
+		s.drawn = append(s.drawn, value)
+		return value
+	}
+	if s.next < len(s.drawn) {
+		s.next++
+		return s.drawn[s.next-1]
+	}
+	return s.extra.Uint64()
+}
+
+// Int63 implements rand.Source
+func (s *replaySource) Int63() int64 {
+	if !s.replaying {
+		value := s.src.Int63()
+		s.drawn = append(s.drawn, uint64(value))
+		return value
+	}
+	if s.next < len(s.drawn) {
+		s.next++
+		return int64(s.drawn[s.next-1] & (1<<63 - 1))
+	}
+	return s.extra.Int63()
+}
+
+// Seed implements rand.Source
+func (s *replaySource) Seed(seed int64) {
+	s.extra.Seed(seed)
+}
+
+// EvalWithGradient evaluates the program with the given variables, along with the partial
+// derivatives of its result with respect to every variable
+func (p *Program) EvalWithGradient(variables Variables) (float64, map[string]float64, error) {
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+
+	if err := p.SetVariables(env, variables); err != nil {
+		return 0, nil, err
+	}
+	partials := make([]float64, len(p.variables))
+	result, err := p.EvalGradient(env, partials)
+	if err != nil {
+		return 0, nil, err
+	}
+	gradient := make(map[string]float64, len(p.variables))
+	for slot, name := range p.variables {
+		gradient[name] = partials[slot]
+	}
+	return result, gradient, nil
+}
+
+// EvalGradient evaluates the program in an environment over dual numbers, writing the partial
+// derivatives of its result with respect to its variables in gradient, by slot. It runs the
+// bytecode whatever the backend, computing the derivatives of the elementary functions exactly
+// and those of the other functions by central finite differences. Random functions are
+// differentiated for the numbers they drew, like functions of their arguments, and the integer
+// operators cannot be differentiated.
+func (p *Program) EvalGradient(env *Env, gradient []float64) (float64, error) {
+	n := len(p.variables)
+	if len(gradient) != n {
+		return 0, ErrSlotCount{Expected: n, Got: len(gradient)}
+	}
+	g := env.gradientState(p.stackSize*n, n, p.calls)
+	stack := env.stack
+	slots := env.slots
+	tangents := g.tangents
+	row := g.row
+	sp := 0
+	checked := p.policy.policy == ErrorPolicy
+
+	for i := range p.code {
+		ins := &p.code[i]
+		switch ins.op {
+		case opConst:
+			stack[sp] = ins.value
+			clear(tangents[sp*n : (sp+1)*n])
+			sp++
+		case opLoad:
+			stack[sp] = slots[ins.arg]
+			clear(tangents[sp*n : (sp+1)*n])
+			tangents[sp*n+ins.arg] = 1
+			sp++
+		case opNeg:
+			stack[sp-1] = -stack[sp-1]
+			for j := (sp - 1) * n; j < sp*n; j++ {
+				tangents[j] = -tangents[j]
+			}
+		case opNot, opAnd, opOr, opXor, opShl, opShr:
+			return 0, ErrNotDifferentiable{Function: string(operators[ins.op])}
+		case opAdd, opSub, opMul, opDiv, opPow:
+			sp--
+			a, b := stack[sp-1], stack[sp]
+			// the factors of the partial derivatives of a and b in those of the result
+			var result, da, db float64
+			switch ins.op {
+			case opAdd:
+				result, da, db = a+b, 1, 1
+			case opSub:
+				result, da, db = a-b, 1, -1
+			case opMul:
+				result, da, db = a*b, b, a
+			case opDiv:
+				result = a / b
+				da, db = 1/b, -result/b
+			case opPow:
+				if err := p.limits.checkExponent(b); err != nil {
+					return 0, err
+				}
+				result = math.Pow(a, b)
+				da, db = b*math.Pow(a, b-1), result*math.Log(a)
+			}
+			stack[sp-1] = result
+			ta, tb := tangents[(sp-1)*n:sp*n], tangents[sp*n:(sp+1)*n]
+			for j := range ta {
+				ta[j] = scaleTangent(da, ta[j]) + scaleTangent(db, tb[j])
+			}
+		case opCall, opCallRand:
+			c := &p.calls[ins.arg]
+			sp -= c.argc
+			args := stack[sp : sp+c.argc]
+			fn := c.fn
+			var result float64
+			if ins.op == opCallRand {
+				// the numbers drawn from the environment for the result are drawn again while the
+				// function is differentiated
+				g.source.record(env.random())
+				result = c.randFn(g.replay, args...)
+				fn = func(args ...float64) float64 {
+					g.source.rewind()
+					return c.randFn(g.replay, args...)
+				}
+			}
+			partials := g.partials[:c.argc]
+			callPartials(c, fn, args, tangents[sp*n:(sp+c.argc)*n], partials)
+			clear(row)
+			for k, partial := range partials {
+				for j := range row {
+					row[j] += scaleTangent(partial, tangents[(sp+k)*n+j])
+				}
+			}
+			if ins.op == opCall {
+				result = fn(args...)
+			}
+			stack[sp] = result
+			copy(tangents[sp*n:(sp+1)*n], row)
+			sp++
+		}
+
+		if checked && ins.op > opLoad {
+			// the divisor of a division is left just above its result
+			divisor := math.NaN()
+			if ins.op == opDiv {
+				divisor = stack[sp]
+			}
+			if err := checkFinite(p.instructionName(ins), stack[sp-1], divisor); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	result := p.policy.apply(stack[0])
+	if result != stack[0] && !math.IsNaN(result) {
+		// a substitute is constant
+		clear(gradient)
+	} else {
+		copy(gradient, tangents[:n])
+	}
+	return result, nil
+}
+
+// scaleTangent multiplies a partial derivative by a factor, ignoring the factor where the
+// derivative is zero so that the undefined factors of constant operands do not spread
+func scaleTangent(factor, tangent float64) float64 {
+	if tangent == 0 {
+		return 0
+	}
+	return factor * tangent
+}
+
+// callPartials computes the partial derivatives of a call with respect to its arguments, leaving
+// zero for the arguments that do not depend on any variable
+func callPartials(c *call, fn Function, args, tangents, partials []float64) {
+	n := len(tangents) / max(len(args), 1)
+	for k := range partials {
+		partials[k] = 0
+		for _, tangent := range tangents[k*n : (k+1)*n] {
+			if tangent != 0 {
+				partials[k] = math.NaN()
+				break
+			}
+		}
+	}
+
+	switch {
+	case c.derivative != nil:
+		if math.IsNaN(partials[0]) {
+			partials[0] = c.derivative(args[0])
+		}
+		return
+	case c.name == "max" || c.name == "min":
+		// the selected argument, the first one on ties
+		selected := 0
+		for k, arg := range args {
+			if c.name == "max" && arg > args[selected] || c.name == "min" && arg < args[selected] {
+				selected = k
+			}
+		}
+		for k := range partials {
+			if k == selected && math.IsNaN(partials[k]) {
+				partials[k] = 1
+			} else {
+				partials[k] = 0
+			}
+		}
+		return
+	}
+
+	for k, arg := range args {
+		if !math.IsNaN(partials[k]) {
+			continue
+		}
+		// the step balancing the truncation and rounding errors of a central difference
+		h := math.Cbrt(epsilon) * max(1, math.Abs(arg))
+		args[k] = arg + h
+		above := fn(args...)
+		args[k] = arg - h
+		below := fn(args...)
+		args[k] = arg
+		partials[k] = (above - below) / (2 * h)
+	}
+}
+
+// gradientState returns the buffers of gradient evaluations, growing them as needed
+func (env *Env) gradientState(tangents, variables int, calls []call) *gradientState {
+	if env.gradient == nil {
+		env.gradient = &gradientState{}
+	}
+	g := env.gradient
+	if len(g.tangents) < tangents {
+		g.tangents = make([]float64, tangents)
+	}
+	if len(g.row) != variables {
+		g.row = make([]float64, variables)
+	}
+	for _, c := range calls {
+		if len(g.partials) < c.argc {
+			g.partials = make([]float64, c.argc)
+		}
+		if c.randFn != nil && g.replay == nil {
+			g.source = &replaySource{extra: rand.NewSource(0).(rand.Source64)}
+			g.replay = rand.New(g.source)
+		}
+	}
+	return g
+}
@@ -0,0 +1,214 @@
+package nparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestProgramEvalWithGradient(t *testing.T) {
+	vars := Variables{"x": 0.7, "y": 2.5, "z": -1.3}
+	expressions := []string{
+		"x * y + z",
+		"x / y - z ^ 2",
+		"-x ^ y",
+		"2 ^ x * y ^ 3",
+		"sin(x) * cos(y) + tan(z)",
+		"cosec(x) + sec(y) + cot(z)",
+		"log(y) + log10(x) + log2(y * x)",
+		"sqrt(x * y)",
+		"max(x, y, z) - min(x, z)",
+		"pmt(x / 100, 12, y * 1000)",
+		"fv(x / 100, 12, -y)",
+		"x",
+		"3",
+	}
+
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		for _, expression := range expressions {
+			np := New(expression)
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatalf("%s: %v", expression, err)
+			}
+			value, gradient, err := program.EvalWithGradient(vars)
+			if err != nil {
+				t.Fatalf("%s: %v", expression, err)
+			}
+			expected, err := program.Run(vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != expected {
+				t.Errorf("%s: expected the value %v, got %v", expression, expected, value)
+			}
+			if len(gradient) != len(program.Variables()) {
+				t.Errorf("%s: expected a partial derivative for every variable, got %v", expression, gradient)
+			}
+
+			for _, name := range program.Variables() {
+				// checked against the derivative computed with series
+				derivative, err := program.Nderiv(name, vars[name], vars)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(gradient[name]-derivative) > 1e-6*max(1, math.Abs(derivative)) {
+					t.Errorf("%s: expected the partial derivative %v for %s, got %v", expression, derivative, name, gradient[name])
+				}
+			}
+		}
+	}
+}
+
+func TestProgramEvalWithGradientSpecialCases(t *testing.T) {
+	// random functions are differentiated for the numbers they drew
+	program, err := Compile("uniform(a, b)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, gradient, err := program.EvalWithGradient(Variables{"a": 1, "b": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value < 1 || value > 3 || math.Abs(gradient["a"]+gradient["b"]-1) > 1e-6 || math.Abs(1+2*gradient["b"]-value) > 1e-6 {
+		t.Errorf("unexpected gradient %v for the value %v", gradient, value)
+	}
+
+	// a constant operand that is not differentiable does not spread
+	program, err = Compile("(-2) ^ x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, gradient, _ := program.EvalWithGradient(Variables{"x": 2}); !math.IsNaN(gradient["x"]) {
+		t.Errorf("expected an undefined partial derivative, got %v", gradient)
+	}
+	program, err = Compile("x ^ 2 * 0 ^ 0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, gradient, _ := program.EvalWithGradient(Variables{"x": 3}); gradient["x"] != 0 {
+		t.Errorf("expected a zero partial derivative, got %v", gradient)
+	}
+
+	// a substitute is constant
+	np := New("log(x)")
+	np.SetPolicy(SubstitutePolicy, 0)
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, gradient, _ := program.EvalWithGradient(Variables{"x": -1}); value != 0 || gradient["x"] != 0 {
+		t.Errorf("expected a constant substitute, got %v and %v", value, gradient)
+	}
+
+	np = New("1 / x")
+	np.SetPolicy(ErrorPolicy, 0)
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := program.EvalWithGradient(Variables{"x": 0}); !errors.Is(err, ErrDivisionByZero{}) {
+		t.Errorf("expected ErrDivisionByZero, got %v", err)
+	}
+	if _, _, err := program.EvalWithGradient(nil); !errors.Is(err, ErrUndefinedVariable{Variable: "x"}) {
+		t.Errorf("expected ErrUndefinedVariable, got %v", err)
+	}
+	if _, err := program.EvalGradient(program.NewEnv(), nil); !errors.Is(err, ErrSlotCount{Expected: 1, Got: 0}) {
+		t.Errorf("expected ErrSlotCount, got %v", err)
+	}
+}
+
+func TestProgramEvalGradientWithSeed(t *testing.T) {
+	for _, expression := range []string{"x * uniform(0, 1)", "normal(x, 2) + randint(1, 6) * rand() ^ x"} {
+		program, err := Compile(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := program.NewEnv()
+		env.SetSeed(7)
+		if err := program.SetVariables(env, Variables{"x": 2}); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := program.Eval(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.SetSeed(7)
+		value, err := program.EvalGradient(env, make([]float64, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("%s: expected the value %v of Eval for the same seed, got %v", expression, expected, value)
+		}
+	}
+}
+
+func TestIntegerOperatorsAreNotDifferentiable(t *testing.T) {
+	for _, operator := range []string{AND, OR, XOR, SHL, SHR} {
+		np := New("x " + operator + " 1")
+		np.SetIntegerMode(true)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ErrNotDifferentiable{Function: operator}
+		if _, _, err := program.EvalWithGradient(Variables{"x": 3}); !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v from EvalWithGradient, got %v", operator, expected, err)
+		}
+		if _, err := program.Taylor("x", 3, 2, nil); !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v from Taylor, got %v", operator, expected, err)
+		}
+	}
+
+	np := New("~x + y")
+	np.SetIntegerMode(true)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ErrNotDifferentiable{Function: NOT}
+	if _, _, err := program.EvalWithGradient(Variables{"x": 3, "y": 1}); !errors.Is(err, expected) {
+		t.Errorf("expected %v from EvalWithGradient, got %v", expected, err)
+	}
+	if _, err := program.Nderiv("y", 1, Variables{"x": 3}); !errors.Is(err, expected) {
+		t.Errorf("expected %v from Nderiv, got %v", expected, err)
+	}
+}
+
+func TestProgramEvalGradientAllocations(t *testing.T) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := program.NewEnv()
+	if err := program.SetVariables(env, benchmarkVariables); err != nil {
+		t.Fatal(err)
+	}
+	gradient := make([]float64, len(program.Variables()))
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := program.EvalGradient(env, gradient); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per evaluation, got %v", allocs)
+	}
+}
+
+func BenchmarkProgramEvalGradient(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := program.NewEnv()
+	if err := program.SetVariables(env, benchmarkVariables); err != nil {
+		b.Fatal(err)
+	}
+	gradient := make([]float64, len(program.Variables()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		program.EvalGradient(env, gradient)
+	}
+}
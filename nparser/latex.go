@@ -0,0 +1,579 @@
+package nparser
+
+import (
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// latexTokenKind is the kind of a token of LaTeX
+type latexTokenKind int
+
+const (
+	latexEnd latexTokenKind = iota
+	latexNumber
+	latexLetter
+	latexCommand
+	latexSymbol
+)
+
+// latexToken is a token of LaTeX, at a byte offset of the source
+type latexToken struct {
+	kind     latexTokenKind
+	text     string
+	position int
+}
+
+// latexSpacing are the spacing commands, which are skipped
+var latexSpacing = map[string]bool{
+	`\,`: true, `\;`: true, `\:`: true, `\!`: true, `\ `: true, `\quad`: true, `\qquad`: true,
+}
+
+// latexCommandFunctions maps the LaTeX commands of functions to their functions
+var latexCommandFunctions = map[string]string{
+	`\sin`: "sin",
+	`\cos`: "cos",
+	`\tan`: "tan",
+	`\csc`: "cosec",
+	`\sec`: "sec",
+	`\cot`: "cot",
+	`\ln`:  "log",
+	`\max`: "max",
+	`\min`: "min",
+}
+
+// latexLogBases maps the subscripts of \log to their functions
+var latexLogBases = map[float64]string{
+	10: "log10",
+	2:  "log2",
+}
+
+// latexNames are the commands that write a name of several letters
+var latexNames = map[string]bool{
+	`\mathrm`: true, `\text`: true, `\operatorname`: true, `\mathit`: true,
+}
+
+// latexGreek maps the commands of greek letters to the letters, which are variables
+var latexGreek = map[string]string{
+	`\alpha`: "α", `\beta`: "β", `\gamma`: "γ", `\delta`: "δ", `\epsilon`: "ε", `\varepsilon`: "ε",
+	`\zeta`: "ζ", `\eta`: "η", `\theta`: "θ", `\iota`: "ι", `\kappa`: "κ", `\lambda`: "λ",
+	`\mu`: "μ", `\nu`: "ν", `\xi`: "ξ", `\rho`: "ρ", `\sigma`: "σ", `\tau`: "τ",
+	`\phi`: "φ", `\varphi`: "φ", `\chi`: "χ", `\psi`: "ψ", `\omega`: "ω",
+	`\Gamma`: "Γ", `\Delta`: "Δ", `\Theta`: "Θ", `\Lambda`: "Λ", `\Sigma`: "Σ", `\Phi`: "Φ", `\Omega`: "Ω",
+}
+
+// latexClosing maps the opening brackets to their closing brackets
+var latexClosing = map[string]string{
+	LPAREN: RPAREN,
+	"[":    "]",
+	"{":    "}",
+}
+
+// latexParser parses a subset of LaTeX by recursive descent
+type latexParser struct {
+	tokens []latexToken
+	next   int
+	limits Limits
+	depth  int
+	// callDepth is the nesting of the functions being parsed
+	callDepth int
+}
+
+// ParseLaTeX parses the expression as LaTeX to the same tree as its native syntax. It
+// supports \frac, \sqrt, superscripts, \cdot, \times, \div, \left and \right, the commands
+// of functions, \pi, greek letters and implicit multiplication, where every letter is a variable.
+func (np *Nparser) ParseLaTeX() (*Node, error) {
+	tokens, err := tokenizeLaTeX(string(np.expression))
+	if err != nil {
+		return nil, err
+	}
+	if err := np.limits.check(LimitTokens, len(tokens)-1, np.limits.MaxTokens); err != nil {
+		return nil, err
+	}
+
+	p := &latexParser{tokens: tokens, limits: np.limits}
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != latexEnd {
+		return nil, p.fail(token, "unexpected")
+	}
+	return root, nil
+}
+
+// CompileLaTeX parses the expression as LaTeX and compiles it to a Program
+func (np *Nparser) CompileLaTeX() (*Program, error) {
+	root, err := np.ParseLaTeX()
+	if err != nil {
+		return nil, err
+	}
+	return np.compileTree(root)
+}
+
+// CompileLaTeX parses an expression written in LaTeX and compiles it to a Program
+func CompileLaTeX(expression string) (*Program, error) {
+	return New(expression).CompileLaTeX()
+}
+
+// tokenizeLaTeX splits LaTeX into tokens, ending with a token of kind latexEnd
+func tokenizeLaTeX(source string) ([]latexToken, error) {
+	var tokens []latexToken
+	for i := 0; i < len(source); {
+		ch, size := utf8.DecodeRuneInString(source[i:])
+		start := i
+		switch {
+		case unicode.IsSpace(ch):
+			i += size
+			continue
+		case ch == '\\':
+			i++
+			for i < len(source) && (source[i] >= 'a' && source[i] <= 'z' || source[i] >= 'A' && source[i] <= 'Z') {
+				i++
+			}
+			if i == start+1 {
+				// a command of a single symbol, such as \, or \{
+				if i == len(source) {
+					return nil, ErrLaTeX{Position: start, Command: `\`, Reason: "incomplete command"}
+				}
+				_, size := utf8.DecodeRuneInString(source[i:])
+				i += size
+			}
+			if latexSpacing[source[start:i]] {
+				continue
+			}
+			tokens = append(tokens, latexToken{kind: latexCommand, text: source[start:i], position: start})
+			continue
+		case ch >= '0' && ch <= '9' || ch == '.':
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, latexToken{kind: latexNumber, text: source[start:i], position: start})
+			continue
+		case unicode.IsLetter(ch):
+			tokens = append(tokens, latexToken{kind: latexLetter, text: string(ch), position: start})
+		default:
+			tokens = append(tokens, latexToken{kind: latexSymbol, text: string(ch), position: start})
+		}
+		i += size
+	}
+	return append(tokens, latexToken{kind: latexEnd, position: len(source)}), nil
+}
+
+// peek returns the next token without consuming it
+func (p *latexParser) peek() latexToken {
+	return p.tokens[p.next]
+}
+
+// consume returns the next token and moves past it
+func (p *latexParser) consume() latexToken {
+	token := p.tokens[p.next]
+	if token.kind != latexEnd {
+		p.next++
+	}
+	return token
+}
+
+// accept consumes the next token if it is the given symbol or command
+func (p *latexParser) accept(text string) bool {
+	token := p.peek()
+	if (token.kind == latexSymbol || token.kind == latexCommand) && token.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// fail returns an error pointing at a token
+func (p *latexParser) fail(token latexToken, reason string) error {
+	command := token.text
+	if token.kind == latexEnd {
+		command = "end of expression"
+	}
+	return ErrLaTeX{Position: token.position, Command: command, Reason: reason}
+}
+
+// expression parses sums and differences of terms
+func (p *latexParser) expression() (*Node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var operator string
+		switch {
+		case p.accept(PLUS):
+			operator = PLUS
+		case p.accept(MINUS):
+			operator = MINUS
+		default:
+			return left, nil
+		}
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Node{Kind: OperatorNode, Name: operator, Args: []*Node{left, right}}
+	}
+}
+
+// term parses products and quotients of factors, written or implicit
+func (p *latexParser) term() (*Node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var right *Node
+		operator := MUL
+		switch {
+		case p.accept(MUL) || p.accept(`\cdot`) || p.accept(`\times`):
+			right, err = p.unary()
+		case p.accept(DIV) || p.accept(`\div`):
+			operator = DIV
+			right, err = p.unary()
+		case p.startsFactor():
+			right, err = p.power()
+		default:
+			return left, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		left = &Node{Kind: OperatorNode, Name: operator, Args: []*Node{left, right}}
+	}
+}
+
+// unary parses a factor with its signs
+func (p *latexParser) unary() (*Node, error) {
+	if p.accept(MINUS) {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: OperatorNode, Name: UMINUS, Args: []*Node{operand}}, nil
+	}
+	if p.accept(PLUS) {
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power parses a factor with its superscript
+func (p *latexParser) power() (*Node, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	return p.superscript(base)
+}
+
+// superscript parses the superscript of a base, if any
+func (p *latexParser) superscript(base *Node) (*Node, error) {
+	if token := p.peek(); token.kind == latexSymbol && token.text == "_" {
+		return nil, p.fail(token, "unsupported subscript")
+	}
+	if !p.accept(POW) {
+		return base, nil
+	}
+	exponent, err := p.script()
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Kind: OperatorNode, Name: POW, Args: []*Node{base, exponent}}, nil
+}
+
+// script parses a superscript or subscript: a group, or else a single character or command
+func (p *latexParser) script() (*Node, error) {
+	token := p.peek()
+	switch {
+	case token.kind == latexSymbol && token.text == "{":
+		return p.group()
+	case token.kind == latexSymbol && token.text == MINUS:
+		p.consume()
+		operand, err := p.script()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: OperatorNode, Name: UMINUS, Args: []*Node{operand}}, nil
+	case token.kind == latexNumber && len(token.text) > 1:
+		// x^23 is x squared times 3
+		p.tokens[p.next].text = token.text[1:]
+		p.tokens[p.next].position++
+		return p.number(latexToken{kind: latexNumber, text: token.text[:1], position: token.position})
+	}
+	return p.primary()
+}
+
+// group parses an expression in braces
+func (p *latexParser) group() (*Node, error) {
+	token := p.peek()
+	if !p.accept("{") {
+		return nil, p.fail(token, "expected { before")
+	}
+	return p.enclosed(token, "}", "")
+}
+
+// enclosed parses an expression up to a closing bracket, preceded by the given command if any
+func (p *latexParser) enclosed(opening latexToken, closing, command string) (*Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.limits.check(LimitDepth, p.depth, p.limits.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	node, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.close(opening, closing, command); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// close consumes a closing bracket, preceded by the given command if any
+func (p *latexParser) close(opening latexToken, closing, command string) error {
+	if command != "" && !p.accept(command) || !p.accept(closing) {
+		return p.fail(opening, "unbalanced")
+	}
+	return nil
+}
+
+// startsFactor checks if the next token starts a factor, which multiplies the factor before it
+func (p *latexParser) startsFactor() bool {
+	token := p.peek()
+	switch token.kind {
+	case latexNumber, latexLetter:
+		return true
+	case latexSymbol:
+		return token.text == LPAREN || token.text == "{"
+	case latexCommand:
+		_, isFunction := latexCommandFunctions[token.text]
+		_, isGreek := latexGreek[token.text]
+		return isFunction || isGreek || latexNames[token.text] ||
+			token.text == `\frac` || token.text == `\dfrac` || token.text == `\tfrac` ||
+			token.text == `\sqrt` || token.text == `\left` || token.text == `\log` || token.text == `\pi`
+	}
+	return false
+}
+
+// startsCall checks if the next token is the command of a function
+func (p *latexParser) startsCall() bool {
+	token := p.peek()
+	_, isFunction := latexCommandFunctions[token.text]
+	return token.kind == latexCommand && (isFunction || token.text == `\log`)
+}
+
+// number parses a number token
+func (p *latexParser) number(token latexToken) (*Node, error) {
+	value, err := strconv.ParseFloat(token.text, 64)
+	if err != nil {
+		return nil, p.fail(token, "invalid number")
+	}
+	return &Node{Kind: NumberNode, Value: value}, nil
+}
+
+// primary parses a number, a variable, a command or an expression in brackets
+func (p *latexParser) primary() (*Node, error) {
+	token := p.consume()
+	switch token.kind {
+	case latexNumber:
+		return p.number(token)
+	case latexLetter:
+		return &Node{Kind: VariableNode, Name: token.text}, nil
+	case latexSymbol:
+		if closing, ok := latexClosing[token.text]; ok {
+			return p.enclosed(token, closing, "")
+		}
+		return nil, p.fail(token, "unexpected")
+	case latexEnd:
+		return nil, p.fail(token, "missing operand before")
+	}
+
+	if name, ok := latexCommandFunctions[token.text]; ok {
+		return p.call(token, name)
+	}
+	if letter, ok := latexGreek[token.text]; ok {
+		return &Node{Kind: VariableNode, Name: letter}, nil
+	}
+	if latexNames[token.text] {
+		return p.name(token)
+	}
+
+	switch token.text {
+	case `\pi`:
+		return &Node{Kind: NumberNode, Value: math.Pi}, nil
+	case `\left`:
+		opening := p.consume()
+		closing, ok := latexClosing[opening.text]
+		if opening.kind == latexCommand && opening.text == `\{` {
+			closing, ok = `\}`, true
+		}
+		if !ok {
+			return nil, p.fail(opening, "unsupported bracket")
+		}
+		return p.enclosed(token, closing, `\right`)
+	case `\frac`, `\dfrac`, `\tfrac`:
+		numerator, err := p.group()
+		if err != nil {
+			return nil, err
+		}
+		denominator, err := p.group()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: OperatorNode, Name: DIV, Args: []*Node{numerator, denominator}}, nil
+	case `\sqrt`:
+		var degree *Node
+		if opening := p.peek(); p.accept("[") {
+			var err error
+			if degree, err = p.enclosed(opening, "]", ""); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.enterCall(); err != nil {
+			return nil, err
+		}
+		radicand, err := p.group()
+		p.callDepth--
+		if err != nil {
+			return nil, err
+		}
+		if degree == nil {
+			return &Node{Kind: CallNode, Name: "sqrt", Args: []*Node{radicand}}, nil
+		}
+		// the root of degree n is the power 1/n
+		exponent := &Node{Kind: OperatorNode, Name: DIV, Args: []*Node{{Kind: NumberNode, Value: 1}, degree}}
+		return &Node{Kind: OperatorNode, Name: POW, Args: []*Node{radicand, exponent}}, nil
+	case `\log`:
+		if !p.accept("_") {
+			return p.call(token, "log")
+		}
+		base, err := p.script()
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := latexLogBases[base.Value]; ok && base.Kind == NumberNode {
+			return p.call(token, name)
+		}
+		return nil, p.fail(token, "unsupported base of logarithm")
+	}
+	return nil, p.fail(token, "unsupported command")
+}
+
+// name parses a name of several letters given to a command such as \mathrm, a variable or a function
+func (p *latexParser) name(command latexToken) (*Node, error) {
+	if !p.accept("{") {
+		return nil, p.fail(command, "expected { after")
+	}
+	name := ""
+	for token := p.peek(); token.kind == latexLetter || token.kind == latexNumber && name != ""; token = p.peek() {
+		name += p.consume().text
+	}
+	if name == "" || !p.accept("}") {
+		return nil, p.fail(command, "expected a name in braces after")
+	}
+
+	next := p.peek()
+	isCall := next.kind == latexSymbol && next.text == LPAREN || next.kind == latexCommand && next.text == `\left`
+	if function, ok := functionName(name); ok && isCall {
+		return p.call(command, function)
+	}
+	return &Node{Kind: VariableNode, Name: name}, nil
+}
+
+// call parses the arguments of a function, in brackets, in braces, or as an implicit product
+// as in \sin 2x, and the power the function is raised to as in \sin^2 x
+func (p *latexParser) call(command latexToken, name string) (*Node, error) {
+	if err := p.enterCall(); err != nil {
+		return nil, err
+	}
+	defer func() { p.callDepth-- }()
+
+	var exponent *Node
+	if p.accept(POW) {
+		var err error
+		if exponent, err = p.script(); err != nil {
+			return nil, err
+		}
+	}
+
+	var args []*Node
+	opening := p.peek()
+	switch {
+	case opening.kind == latexSymbol && opening.text == LPAREN:
+		p.consume()
+		var err error
+		if args, err = p.arguments(opening, RPAREN, ""); err != nil {
+			return nil, err
+		}
+	case opening.kind == latexCommand && opening.text == `\left`:
+		p.consume()
+		if !p.accept(LPAREN) {
+			return nil, p.fail(p.peek(), "expected ( before")
+		}
+		var err error
+		if args, err = p.arguments(opening, RPAREN, `\right`); err != nil {
+			return nil, err
+		}
+	default:
+		// the argument stops before another function, as in \sin x \cos x
+		arg, err := p.power()
+		for err == nil && p.startsFactor() && !p.startsCall() {
+			var factor *Node
+			if factor, err = p.power(); err == nil {
+				arg = &Node{Kind: OperatorNode, Name: MUL, Args: []*Node{arg, factor}}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = []*Node{arg}
+	}
+
+	if !functionList[name].accepts(len(args)) {
+		return nil, p.fail(command, "wrong number of arguments to")
+	}
+	node := &Node{Kind: CallNode, Name: name, Args: args}
+	if exponent != nil {
+		node = &Node{Kind: OperatorNode, Name: POW, Args: []*Node{node, exponent}}
+	}
+	return p.superscript(node)
+}
+
+// enterCall counts a function being parsed against the limit on the nesting of calls
+func (p *latexParser) enterCall() error {
+	p.callDepth++
+	return p.limits.check(LimitCallDepth, p.callDepth, p.limits.MaxCallDepth)
+}
+
+// arguments parses the arguments of a function separated by commas, up to a closing bracket
+func (p *latexParser) arguments(opening latexToken, closing, command string) ([]*Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.limits.check(LimitDepth, p.depth, p.limits.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	var args []*Node
+	if token := p.peek(); token.text == closing || command != "" && token.text == command {
+		// a function without arguments
+		return nil, p.close(opening, closing, command)
+	}
+	for {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.accept(COMMA) {
+			break
+		}
+	}
+	if err := p.close(opening, closing, command); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
@@ -0,0 +1,110 @@
+package nparser
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseLaTeXMatchesNativeSyntax(t *testing.T) {
+	tests := []struct {
+		latex  string
+		native string
+	}{
+		{`\frac{a}{b} + \sqrt{x^{2}}`, "a / b + sqrt(x ^ 2)"},
+		{`2x + 3`, "2 * x + 3"},
+		{`a b c`, "a * b * c"},
+		{`x^23`, "x ^ 2 * 3"},
+		{`-x^2`, "-x ^ 2"},
+		{`2^{-1}`, "2 ^ -1"},
+		{`a \cdot b \times c \div d`, "a * b * c / d"},
+		{`\left(x + 1\right)(x - 1)`, "(x + 1) * (x - 1)"},
+		{`\sin x + 1`, "sin(x) + 1"},
+		{`\sin 2x`, "sin(2 * x)"},
+		{`2\sin x \cos x`, "2 * sin(x) * cos(x)"},
+		{`\sin^2 x + \cos^{2}(x)`, "sin(x) ^ 2 + cos(x) ^ 2"},
+		{`\ln\left(x\right) + \log_{10} 100 + \log_2{8}`, "log(x) + log10(100) + log2(8)"},
+		{`\max(a, b, c)`, "max(a, b, c)"},
+		{`\operatorname{pmt}(\mathrm{rate}, 10, 1000)`, "pmt(rate, 10, 1000)"},
+		{`\sqrt[3]{x}`, "x ^ (1 / 3)"},
+		{`2\pi r`, "2 * π * r"},
+		{`\alpha\,\beta`, "α * β"},
+		{`{a + b}^{2}`, "(a + b) ^ 2"},
+	}
+
+	for _, test := range tests {
+		tree, err := New(test.latex).ParseLaTeX()
+		if err != nil {
+			t.Fatalf("%s: %v", test.latex, err)
+		}
+		expected, err := New(test.native).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.native, err)
+		}
+		if !reflect.DeepEqual(tree, expected) {
+			t.Errorf("%s: parsed as %s, expected %s", test.latex, tree, expected)
+		}
+	}
+}
+
+func TestCompileLaTeX(t *testing.T) {
+	program, err := CompileLaTeX(`\frac{a}{b} + \sqrt{x^{2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := program.Run(Variables{"a": 1, "b": 4, "x": -3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(result-3.25) > 1e-12 {
+		t.Errorf("expected 3.25, got %v", result)
+	}
+}
+
+func TestParseLaTeXErrors(t *testing.T) {
+	tests := []struct {
+		latex    string
+		command  string
+		position int
+	}{
+		{`\frac{a}{b} + \int x`, `\int`, 14},
+		{`\frac{a} b`, "b", 9},
+		{`x_1`, "_", 1},
+		{`\left( x`, `\left`, 0},
+		{`\sin(a, b)`, `\sin`, 0},
+		{`2 +`, "end of expression", 3},
+		{`\log_{3} x`, `\log`, 0},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.latex).ParseLaTeX()
+		var latexErr ErrLaTeX
+		if !errors.As(err, &latexErr) {
+			t.Errorf("%s: expected ErrLaTeX, got %v", test.latex, err)
+			continue
+		}
+		if latexErr.Command != test.command || latexErr.Position != test.position {
+			t.Errorf("%s: expected an error at %s (position %d), got %v", test.latex, test.command, test.position, err)
+		}
+	}
+}
+
+func TestLaTeXCallDepth(t *testing.T) {
+	for _, expression := range []string{`\sin\sin\sin x`, `\sin(\cos(\tan(1)))`, `\sqrt{\sqrt{\sqrt{2}}}`, `\log \sin \cos x`} {
+		np := New(expression)
+		np.SetLimits(Limits{MaxCallDepth: 2})
+		_, err := np.ParseLaTeX()
+		var exceeded ErrLimitExceeded
+		if !errors.As(err, &exceeded) || exceeded.Limit != LimitCallDepth {
+			t.Errorf("%s: expected the call depth limit to be exceeded, got %v", expression, err)
+		}
+	}
+	for _, expression := range []string{`\sin\cos x + \sqrt{\tan x}`, `\sin x \cos x \tan x`} {
+		np := New(expression)
+		np.SetLimits(Limits{MaxCallDepth: 2})
+		if _, err := np.ParseLaTeX(); err != nil {
+			t.Errorf("%s: %v", expression, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package nparser
+
+import (
+	"context"
+	"math"
+)
+
+// Limits bounds the resources used to parse and evaluate an expression. A zero field is no limit.
+type Limits struct {
+	// MaxTokens is the maximum number of tokens in the expression
+	MaxTokens int `json:"maxTokens,omitempty"`
+	// MaxDepth is the maximum nesting of parentheses
+	MaxDepth int `json:"maxDepth,omitempty"`
+	// MaxCallDepth is the maximum nesting of function calls
+	MaxCallDepth int `json:"maxCallDepth,omitempty"`
+	// MaxSteps is the maximum number of operations an evaluation performs
+	MaxSteps int `json:"maxSteps,omitempty"`
+	// MaxExponent is the maximum magnitude of the exponent of ^
+	MaxExponent float64 `json:"maxExponent,omitempty"`
+}
+
+// contextCheckInterval is the number of steps between checks of the context of an evaluation
+const contextCheckInterval = 64
+
+// DefaultLimits are limits suited to evaluating expressions from untrusted sources
+var DefaultLimits = Limits{
+	MaxTokens:    512,
+	MaxDepth:     32,
+	MaxCallDepth: 16,
+	MaxSteps:     1024,
+	MaxExponent:  1024,
+}
+
+// the names of the limits, as reported by ErrLimitExceeded
+const (
+	LimitTokens    = "tokens"
+	LimitDepth     = "depth"
+	LimitCallDepth = "call depth"
+	LimitSteps     = "steps"
+	LimitExponent  = "exponent"
+	LimitDegree    = "degree"
+)
+
+// SetLimits sets the limits enforced by Run and by the programs built by Compile
+func (np *Nparser) SetLimits(limits Limits) {
+	np.limits = limits
+}
+
+// RunContext runs the parser, giving up with the error of the context once it is done
+func (np *Nparser) RunContext(ctx context.Context) (float64, error) {
+	np.ctx = ctx
+	defer func() { np.ctx = nil }()
+	return np.Run()
+}
+
+// RunContext evaluates the program with the given variables, giving up with the error of the context once it is done
+func (p *Program) RunContext(ctx context.Context, variables Variables) (float64, error) {
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+
+	if err := p.SetVariables(env, variables); err != nil {
+		return 0, err
+	}
+	return p.EvalContext(ctx, env)
+}
+
+// EvalContext evaluates the program in an environment, giving up with the error of the context
+// once it is done. The context is checked before the evaluation and every contextCheckInterval
+// steps of it.
+func (p *Program) EvalContext(ctx context.Context, env *Env) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	env.ctx = ctx
+	defer func() { env.ctx = nil }()
+	return p.Eval(env)
+}
+
+// check fails if a count exceeds a limit
+func (l Limits) check(limit string, count, max int) error {
+	if max > 0 && count > max {
+		return ErrLimitExceeded{Limit: limit, Max: float64(max)}
+	}
+	return nil
+}
+
+// checkTree fails if a tree that was decoded rather than parsed exceeds the limits checked while
+// parsing, on the tokens and the nesting of parentheses and calls of its canonical form
+func (l Limits) checkTree(root *Node) error {
+	if l.MaxTokens <= 0 && l.MaxDepth <= 0 && l.MaxCallDepth <= 0 {
+		return nil
+	}
+	np := New(root.String())
+	np.SetIntegerMode(true)
+	np.SetLimits(l)
+	_, err := np.parse()
+	return err
+}
+
+// checkExponent fails if an exponent exceeds the limit on its magnitude
+func (l Limits) checkExponent(exponent float64) error {
+	if l.MaxExponent > 0 && math.Abs(exponent) > l.MaxExponent {
+		return ErrLimitExceeded{Limit: LimitExponent, Max: l.MaxExponent}
+	}
+	return nil
+}
+
+// checkConstantExponents fails if the tree raises to a constant exponent exceeding the
+// limit, since folding would otherwise evaluate it before the program checks it
+func (l Limits) checkConstantExponents(node *Node) error {
+	if l.MaxExponent <= 0 {
+		return nil
+	}
+	if node.Kind == OperatorNode && node.Name == POW && len(node.Args) == 2 {
+		if exponent := fold(node.Args[1]); exponent.Kind == NumberNode {
+			if err := l.checkExponent(exponent.Value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, arg := range node.Args {
+		if err := l.checkConstantExponents(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
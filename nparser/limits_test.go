@@ -0,0 +1,111 @@
+package nparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		expression string
+		limits     Limits
+		limit      string
+	}{
+		{"1 + 2 + 3", Limits{MaxTokens: 4}, LimitTokens},
+		{"((((1))))", Limits{MaxDepth: 3}, LimitDepth},
+		{"sin(cos(tan(1)))", Limits{MaxCallDepth: 2}, LimitCallDepth},
+		{"x + 1 + 2", Limits{MaxSteps: 4}, LimitSteps},
+		{"2 ^ 2000", Limits{MaxExponent: 1000}, LimitExponent},
+		{"2 ^ (1000 * 3)", Limits{MaxExponent: 1000}, LimitExponent},
+		{"1 ^ x", Limits{MaxExponent: 1000}, LimitExponent},
+	}
+
+	for _, test := range tests {
+		np := New(test.expression)
+		np.SetVariable("x", -5000)
+		np.SetLimits(test.limits)
+		_, err := np.Run()
+		var exceeded ErrLimitExceeded
+		if !errors.As(err, &exceeded) || exceeded.Limit != test.limit {
+			t.Errorf("%s: expected the %s limit to be exceeded by Run, got %v", test.expression, test.limit, err)
+		}
+
+		for _, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err == nil {
+				_, err = program.Run(Variables{"x": -5000})
+			}
+			if !errors.As(err, &exceeded) || exceeded.Limit != test.limit {
+				t.Errorf("%s: expected the %s limit to be exceeded with backend %d, got %v", test.expression, test.limit, backend, err)
+			}
+		}
+	}
+}
+
+func TestLimitsAllowExpressionsWithin(t *testing.T) {
+	np := New("sin(cos(2 ^ x)) + ((1))")
+	np.SetVariable("x", 3)
+	np.SetLimits(Limits{MaxTokens: 16, MaxDepth: 2, MaxCallDepth: 2, MaxSteps: 8, MaxExponent: 3})
+	if _, err := np.Run(); err != nil {
+		t.Error(err)
+	}
+	np.SetLimits(DefaultLimits)
+	if _, err := np.Run(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expression := strings.Repeat("1 + ", 100) + "1"
+	if _, err := New(expression).RunContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from Run, got %v", err)
+	}
+	program, err := Compile(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.RunContext(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from Program, got %v", err)
+	}
+	if result, err := program.RunContext(context.Background(), nil); err != nil || result != 101 {
+		t.Errorf("expected 101, got %v (%v)", result, err)
+	}
+}
+
+func TestEvalContextDuringEvaluation(t *testing.T) {
+	// variables keep the sum from being folded to a constant
+	long := strings.Repeat("x + ", 100) + "x"
+	for _, backend := range backends {
+		for _, policy := range []Policy{PropagatePolicy, ErrorPolicy} {
+			np := New(long)
+			np.SetBackend(backend)
+			np.SetPolicy(policy, 0)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// cancelled once evaluation starts
+			ctx := &expiringContext{Context: context.Background(), limit: 1}
+			if _, err := program.RunContext(ctx, Variables{"x": 1}); !errors.Is(err, context.Canceled) {
+				t.Errorf("backend %d: expected context.Canceled during evaluation, got %v", backend, err)
+			}
+		}
+
+		np := New("x + 1")
+		np.SetBackend(backend)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := &expiringContext{Context: context.Background(), limit: 1}
+		if result, err := program.RunContext(ctx, Variables{"x": 1}); err != nil || result != 2 {
+			t.Errorf("backend %d: expected a short program to finish, got %v (%v)", backend, result, err)
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package nparser
 
 import (
+	"context"
 	"math"
+	"math/rand"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/viveknathani/numero/nfinance"
 	"github.com/viveknathani/numero/nqueue"
 	"github.com/viveknathani/numero/nstack"
 )
@@ -23,10 +30,29 @@ type Variables map[string]float64
 // Function is a function type
 type Function func(...float64) float64
 
+// RandFunction is a function that draws from a random source
+type RandFunction func(*rand.Rand, ...float64) float64
+
 // FunctionDesc is a function description
 type FunctionDesc struct {
+	// arity is the number of required arguments
 	arity int
-	fn    Function
+	// defaults are the values of the optional arguments that follow the required ones
+	defaults []float64
+	// variadic functions accept any number of arguments beyond arity
+	variadic bool
+	fn       Function
+	// randFn is set instead of fn for functions that draw from the random source.
+	// Such functions give a different result on every evaluation, so they must
+	// never be constant folded or cached.
+	randFn RandFunction
+}
+
+// rpnToken is a token in the output queue; function calls carry their argument count
+type rpnToken struct {
+	token Token
+	call  bool
+	argc  int
 }
 
 // FunctionList is a map of function names to their descriptions
@@ -59,19 +85,71 @@ const (
 
 	// POW is power operator
 	POW = "^"
+
+	// AND is bitwise and operator, available in integer mode
+	AND = "&"
+
+	// OR is bitwise or operator, available in integer mode
+	OR = "|"
+
+	// XOR is bitwise exclusive or operator, available in integer mode
+	XOR = "xor"
+
+	// NOT is bitwise not operator, available in integer mode
+	NOT = "~"
+
+	// SHL is left shift operator, available in integer mode
+	SHL = "<<"
+
+	// SHR is right shift operator, available in integer mode
+	SHR = ">>"
+
+	// SQRT is the square root sign, a prefix operator
+	SQRT = "√"
+
+	// EQUALS separates the sides of an equation, read by ParseEquation
+	EQUALS = "="
 )
 
+// symbolAliases maps mathematical symbols pasted from documents to the tokens they stand for
+var symbolAliases = map[rune]Token{
+	'×': MUL,
+	'÷': DIV,
+	'−': MINUS,
+	'π': Token(strconv.FormatFloat(math.Pi, 'f', -1, 64)),
+}
+
+// superscripts maps superscript characters to the characters they raise
+var superscripts = map[rune]byte{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+	'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+	'⁻': '-',
+}
+
 var operatorList = []Operator{
-	PLUS, MINUS, MUL, DIV, POW, UMINUS,
+	PLUS, MINUS, MUL, DIV, POW, UMINUS, SQRT,
+}
+
+// integerOperatorList holds the operators that are only available in integer mode
+var integerOperatorList = []Operator{
+	AND, OR, XOR, NOT, SHL, SHR,
 }
 
 var precedence = map[Operator]int{
-	PLUS:   1,
-	MINUS:  1,
-	MUL:    2,
-	DIV:    2,
-	POW:    4,
-	UMINUS: 3,
+	EQUALS: 0,
+	OR:     1,
+	XOR:    2,
+	AND:    3,
+	SHL:    4,
+	SHR:    4,
+	PLUS:   5,
+	MINUS:  5,
+	MUL:    6,
+	DIV:    6,
+	UMINUS: 7,
+	NOT:    7,
+	SQRT:   7,
+	POW:    8,
 }
 
 var isLeftAssociative = map[Operator]bool{
@@ -81,6 +159,14 @@ var isLeftAssociative = map[Operator]bool{
 	DIV:    true,
 	POW:    false,
 	UMINUS: false,
+	AND:    true,
+	OR:     true,
+	XOR:    true,
+	NOT:    false,
+	SQRT:   false,
+	SHL:    true,
+	SHR:    true,
+	EQUALS: true,
 }
 
 var functionList = map[string]FunctionDesc{
@@ -94,19 +180,102 @@ var functionList = map[string]FunctionDesc{
 	"log10": {arity: 1, fn: func(args ...float64) float64 { return math.Log10(args[0]) }},
 	"log2":  {arity: 1, fn: func(args ...float64) float64 { return math.Log2(args[0]) }},
 	"sqrt":  {arity: 1, fn: func(args ...float64) float64 { return math.Sqrt(args[0]) }},
-	"max": {arity: 2, fn: func(args ...float64) float64 {
-		return math.Max(args[0], args[1])
+	"max": {arity: 2, variadic: true, fn: func(args ...float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result
+	}},
+	"min": {arity: 2, variadic: true, fn: func(args ...float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result
+	}},
+	"rand": {arity: 0, randFn: func(r *rand.Rand, args ...float64) float64 {
+		return r.Float64()
 	}},
-	"min": {arity: 2, fn: func(args ...float64) float64 {
-		return math.Min(args[0], args[1])
+	"randint": {arity: 2, randFn: func(r *rand.Rand, args ...float64) float64 {
+		return randInt(r, args[0], args[1])
+	}},
+	"uniform": {arity: 2, randFn: func(r *rand.Rand, args ...float64) float64 {
+		return args[0] + r.Float64()*(args[1]-args[0])
+	}},
+	"normal": {arity: 2, randFn: func(r *rand.Rand, args ...float64) float64 {
+		return args[0] + r.NormFloat64()*args[1]
+	}},
+	"pmt": {arity: 3, defaults: []float64{0, 0}, fn: func(args ...float64) float64 {
+		return nfinance.Pmt(args[0], args[1], args[2], args[3], args[4])
+	}},
+	"fv": {arity: 3, defaults: []float64{0, 0}, fn: func(args ...float64) float64 {
+		return nfinance.Fv(args[0], args[1], args[2], args[3], args[4])
+	}},
+	"pv": {arity: 3, defaults: []float64{0, 0}, fn: func(args ...float64) float64 {
+		return nfinance.Pv(args[0], args[1], args[2], args[3], args[4])
+	}},
+	"nper": {arity: 3, defaults: []float64{0, 0}, fn: func(args ...float64) float64 {
+		return nfinance.Nper(args[0], args[1], args[2], args[3], args[4])
+	}},
+	"rate": {arity: 3, defaults: []float64{0, 0, 0.1}, fn: func(args ...float64) float64 {
+		return nfinance.Rate(args[0], args[1], args[2], args[3], args[4], args[5])
+	}},
+	"npv": {arity: 2, variadic: true, fn: func(args ...float64) float64 {
+		return nfinance.Npv(args[0], args[1:]...)
+	}},
+	"irr": {arity: 2, variadic: true, fn: func(args ...float64) float64 {
+		return nfinance.Irr(args, 0.1)
+	}},
+	"irrguess": {arity: 3, variadic: true, fn: func(args ...float64) float64 {
+		return nfinance.Irr(args[1:], args[0])
 	}},
 }
 
+// accepts checks if the function can be called with argc arguments
+func (desc FunctionDesc) accepts(argc int) bool {
+	if argc < desc.arity {
+		return false
+	}
+	return desc.variadic || argc <= desc.arity+len(desc.defaults)
+}
+
+// randInt returns a uniformly distributed integer in [a, b], or NaN if the range is empty or too wide
+func randInt(r *rand.Rand, a, b float64) float64 {
+	lo, hi := math.Ceil(a), math.Floor(b)
+	// a range with an infinite bound, or too wide to count in an int64, cannot be drawn from
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) || hi < lo || hi-lo >= 0x1p63 {
+		return math.NaN()
+	}
+	return lo + float64(r.Int63n(int64(hi-lo)+1))
+}
+
+// functionAliases maps the other names of functions to their canonical names
+var functionAliases = map[string]string{
+	"ln":  "log",
+	"csc": "cosec",
+}
+
 // Nparser is a better parser
 type Nparser struct {
 	pointer    int
 	expression Expression
 	variables  Variables
+	rand       *rand.Rand
+	// integerMode enables the bitwise and shift operators
+	integerMode bool
+	// implicitMultiplication reads operands written next to each other, as in 2x, as a product
+	implicitMultiplication bool
+	// equation reads = as the operator separating the sides of an equation
+	equation bool
+	// pending is a token already scanned by next, to be returned by its following call
+	pending Token
+	// backend is the backend of the programs built by Compile
+	backend Backend
+	limits  Limits
+	policy  resultPolicy
+	// ctx is the context of the evaluation in progress, if any
+	ctx context.Context
 }
 
 // New creates a new Nparser
@@ -122,6 +291,33 @@ func (np *Nparser) SetVariable(name string, value float64) {
 	np.variables[name] = value
 }
 
+// SetSeed seeds the random source used by rand, randint, uniform and normal,
+// making the evaluation reproducible
+func (np *Nparser) SetSeed(seed int64) {
+	np.rand = rand.New(rand.NewSource(seed))
+}
+
+// SetRand sets the random source used by rand, randint, uniform and normal
+func (np *Nparser) SetRand(r *rand.Rand) {
+	np.rand = r
+}
+
+// SetIntegerMode enables or disables the integer operators &, |, xor, ~, << and >>
+func (np *Nparser) SetIntegerMode(enabled bool) {
+	np.integerMode = enabled
+}
+
+// SetImplicitMultiplication enables or disables reading operands written next to each other
+// as a product, so that 2x is 2 * x and (x + 1)(x - 1) is (x + 1) * (x - 1)
+func (np *Nparser) SetImplicitMultiplication(enabled bool) {
+	np.implicitMultiplication = enabled
+}
+
+// SetBackend selects the backend of the programs built by Compile
+func (np *Nparser) SetBackend(backend Backend) {
+	np.backend = backend
+}
+
 // isAnOperator checks if a token is an operator
 func (np *Nparser) isAnOperator(token Token) bool {
 	for _, op := range operatorList {
@@ -129,25 +325,82 @@ func (np *Nparser) isAnOperator(token Token) bool {
 			return true
 		}
 	}
+	return np.integerMode && np.isIntegerOperator(token) || np.equation && token == EQUALS
+}
+
+// isIntegerOperator checks if a token is an operator that is only available in integer mode
+func (np *Nparser) isIntegerOperator(token Token) bool {
+	for _, op := range integerOperatorList {
+		if token == Token(op) {
+			return true
+		}
+	}
 	return false
 }
 
+// isUnary checks if an operator is a prefix operator
+func (np *Nparser) isUnary(token Token) bool {
+	return token == UMINUS || token == NOT || token == SQRT
+}
+
 // next returns the next token, whether it was a valid token, and an error if any
 func (np *Nparser) next() (Token, bool, error) {
 
+	if np.pending != "" {
+		token := np.pending
+		np.pending = ""
+		return token, true, nil
+	}
+
 	np.skipSpaces()
 
 	if np.isEndOfExpression() {
 		return "", false, nil
 	}
 
-	ch := np.expression[np.pointer]
+	ch, size := np.peekRune()
+
+	if np.pointer+1 < len(np.expression) {
+		pair := Token(np.expression[np.pointer : np.pointer+2])
+		if pair == SHL || pair == SHR {
+			if !np.integerMode {
+				return "", false, ErrIntegerModeRequired{Operator: string(pair)}
+			}
+			np.pointer += 2
+			return pair, true, nil
+		}
+	}
+
+	if alias, ok := symbolAliases[ch]; ok {
+		np.pointer += size
+		return alias, true, nil
+	}
+
+	if _, ok := superscripts[ch]; ok {
+		// a run of superscript characters is an exponent
+		var exponent []byte
+		for !np.isEndOfExpression() {
+			ch, size := np.peekRune()
+			raised, ok := superscripts[ch]
+			if !ok {
+				break
+			}
+			exponent = append(exponent, raised)
+			np.pointer += size
+		}
+		np.pending = Token(exponent)
+		return POW, true, nil
+	}
+
+	if !np.integerMode && np.isIntegerOperator(Token(ch)) {
+		return "", false, ErrIntegerModeRequired{Operator: string(ch)}
+	}
 
 	if np.isAnOperator(Token(ch)) ||
 		string(ch) == LPAREN ||
 		string(ch) == RPAREN ||
 		string(ch) == COMMA {
-		np.pointer++
+		np.pointer += size
 		return Token(ch), true, nil
 	}
 
@@ -162,68 +415,158 @@ func (np *Nparser) next() (Token, bool, error) {
 
 	if np.isStartOfVariable(ch) {
 		startIndex := np.pointer
-		for np.pointer < len(np.expression) &&
-			((np.expression[np.pointer] >= 'a' &&
-				np.expression[np.pointer] <= 'z') ||
-				(np.expression[np.pointer] >= 'A' &&
-					np.expression[np.pointer] <= 'Z') ||
-				(np.expression[np.pointer] >= '0' &&
-					np.expression[np.pointer] <= '9') ||
-				(np.expression[np.pointer] == '.')) {
-			np.pointer++
+		for !np.isEndOfExpression() {
+			ch, size := np.peekRune()
+			if !isVariableChar(ch) {
+				break
+			}
+			np.pointer += size
 		}
-		return Token(np.expression[startIndex:np.pointer]), true, nil
+		token := Token(np.expression[startIndex:np.pointer])
+		if token == XOR && !np.integerMode {
+			return "", false, ErrIntegerModeRequired{Operator: XOR}
+		}
+		return token, true, nil
 	}
 
 	return "", false, ErrUnexpectedChar{Char: ch}
 }
 
-// skipSpaces skips all spaces
+// peekRune returns the character at the pointer and its width in bytes
+func (np *Nparser) peekRune() (rune, int) {
+	return utf8.DecodeRuneInString(string(np.expression[np.pointer:]))
+}
+
+// skipSpaces skips all whitespace, including tabs, newlines and non-breaking spaces
 func (np *Nparser) skipSpaces() {
-	for np.pointer < len(np.expression) && np.expression[np.pointer] == ' ' {
-		np.pointer++
+	for !np.isEndOfExpression() {
+		ch, size := np.peekRune()
+		if !unicode.IsSpace(ch) {
+			break
+		}
+		np.pointer += size
 	}
 }
 
+// functionCall checks if the token names a function and is followed by a left parenthesis,
+// so that names like rate or pv remain usable as variables. It returns the canonical name of the function.
+func (np *Nparser) functionCall(token Token) (Token, bool) {
+	name, isFunction := functionName(string(token))
+	if !isFunction {
+		return "", false
+	}
+	np.skipSpaces()
+	return Token(name), !np.isEndOfExpression() && string(np.expression[np.pointer]) == LPAREN
+}
+
+// functionName returns the canonical name of a function, whose names are matched regardless of case
+func functionName(name string) (string, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := functionAliases[name]; ok {
+		name = alias
+	}
+	_, ok := functionList[name]
+	return name, ok
+}
+
 // isEndOfExpression checks if the pointer is at the end of the expression
 func (np *Nparser) isEndOfExpression() bool {
 	return np.pointer >= len(np.expression)
 }
 
 // isPartOfNumber checks if the character is part of a number
-func (np *Nparser) isPartOfNumber(ch byte) bool {
+func (np *Nparser) isPartOfNumber(ch rune) bool {
 	return (ch >= '0' && ch <= '9') || ch == '.'
 }
 
 // isStartOfVariable checks if the character is the start of a variable
-func (np *Nparser) isStartOfVariable(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+func (np *Nparser) isStartOfVariable(ch rune) bool {
+	return unicode.IsLetter(ch)
+}
+
+// isVariableChar checks if a character can follow the first one of a variable name
+func isVariableChar(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '.'
+}
+
+// IsVariableName checks if a name is read as a variable by the parser: a letter followed by
+// letters, digits and dots, other than the xor operator
+func IsVariableName(name string) bool {
+	for i, ch := range name {
+		if i == 0 && !unicode.IsLetter(ch) || !isVariableChar(ch) {
+			return false
+		}
+	}
+	return name != "" && name != XOR
+}
+
+// isStartOfOperand checks if a token starts an operand: a number, a name, a left parenthesis or a square root
+func (np *Nparser) isStartOfOperand(token Token) bool {
+	if token == LPAREN || token == SQRT {
+		return true
+	}
+	ch, _ := utf8.DecodeRuneInString(string(token))
+	return np.isPartOfNumber(ch) || np.isStartOfVariable(ch)
 }
 
 // shouldPop checks if the second operator should be popped from the stack
 func (np *Nparser) shouldPop(o1, o2 Operator) bool {
 	return (precedence[o2] > precedence[o1]) ||
-		(precedence[o2] == precedence[o1] && !isLeftAssociative[o1])
+		(precedence[o2] == precedence[o1] && isLeftAssociative[o1])
 }
 
 // Run runs the parser
 func (np *Nparser) Run() (float64, error) {
+	rpn, err := np.parse()
+	if err != nil {
+		return 0, err
+	}
+	if np.ctx != nil {
+		if err := np.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return np.eval(rpn)
+}
+
+// parse converts the expression to reverse polish notation using the shunting yard algorithm
+func (np *Nparser) parse() (*nqueue.NQueue[rpnToken], error) {
+	np.pointer = 0
+	np.pending = ""
 
 	var prevToken Token
-	outputQueue := nqueue.New[Token]()
+	// prevOperand is true when the previous token ended an operand
+	prevOperand := false
+	outputQueue := nqueue.New[rpnToken]()
 	operatorStack := nstack.New[Token]()
+	// argCounts holds the number of arguments seen so far within every open parenthesis
+	argCounts := nstack.New[int]()
+	// calls holds whether every open parenthesis is a function call
+	calls := nstack.New[bool]()
+	tokens, depth, callDepth := 0, 0, 0
 
 	for {
 		token, ok, err := np.next()
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		if !ok {
 			break
 		}
+		tokens++
+		if err := np.limits.check(LimitTokens, tokens, np.limits.MaxTokens); err != nil {
+			return nil, err
+		}
+
+		if np.implicitMultiplication && prevOperand && np.isStartOfOperand(token) {
+			// the operand is read again after the multiplication
+			np.pending = token
+			token = MUL
+		}
+		operand := false
 
 		if token == MINUS {
-			if prevToken == "" || prevToken == LPAREN || np.isAnOperator(prevToken) {
+			if prevToken == "" || prevToken == LPAREN || prevToken == COMMA || np.isAnOperator(prevToken) {
 				token = UMINUS
 			}
 		}
@@ -232,17 +575,19 @@ func (np *Nparser) Run() (float64, error) {
 			for {
 				topMostOperator, err := operatorStack.Top()
 				if err != nil {
-					return 0, ErrMisplacedComma{}
+					return nil, ErrMisplacedComma{}
 				}
 				if topMostOperator == LPAREN {
 					break
 				}
 				operatorStack.Pop()
-				outputQueue.Enqueue(topMostOperator)
+				outputQueue.Enqueue(rpnToken{token: topMostOperator})
 			}
-			continue
+			count, _ := argCounts.Pop()
+			argCounts.Push(count + 1)
 		} else if np.isAnOperator(token) {
-			for {
+			// prefix operators have no left operand, so nothing binds tighter before them
+			for !np.isUnary(token) {
 				topMostOperator, err := operatorStack.Top()
 				if err != nil {
 					break
@@ -252,33 +597,69 @@ func (np *Nparser) Run() (float64, error) {
 				}
 				if np.shouldPop(Operator(token), Operator(topMostOperator)) {
 					operatorStack.Pop()
-					outputQueue.Enqueue(topMostOperator)
+					outputQueue.Enqueue(rpnToken{token: topMostOperator})
 				} else {
 					break
 				}
 			}
 			operatorStack.Push(token)
 		} else if token == LPAREN {
+			topMostOperator, err := operatorStack.Top()
+			_, isCall := functionList[string(topMostOperator)]
+			isCall = isCall && err == nil
+			if isCall {
+				callDepth++
+			}
+			depth++
 			operatorStack.Push(token)
+			argCounts.Push(1)
+			calls.Push(isCall)
+			if err := np.limits.check(LimitDepth, depth, np.limits.MaxDepth); err != nil {
+				return nil, err
+			}
+			if err := np.limits.check(LimitCallDepth, callDepth, np.limits.MaxCallDepth); err != nil {
+				return nil, err
+			}
 		} else if token == RPAREN {
 			for {
 				topMostOperator, err := operatorStack.Top()
 				if err != nil {
-					return 0, ErrMismatchedParentheses{}
+					return nil, ErrMismatchedParentheses{}
 				}
 				if topMostOperator == LPAREN {
 					operatorStack.Pop()
 					break
 				}
 				operatorStack.Pop()
-				outputQueue.Enqueue(topMostOperator)
+				outputQueue.Enqueue(rpnToken{token: topMostOperator})
 			}
-		} else if _, isFunction := functionList[string(token)]; isFunction {
-			operatorStack.Push(token)
+			argc, _ := argCounts.Pop()
+			depth--
+			if isCall, _ := calls.Pop(); isCall {
+				callDepth--
+			}
+			if prevToken == LPAREN {
+				argc = 0
+			}
+			// a parenthesis preceded by a function closes its call
+			topMostOperator, err := operatorStack.Top()
+			if fn, isFunction := functionList[string(topMostOperator)]; err == nil && isFunction {
+				if !fn.accepts(argc) {
+					return nil, ErrWrongArgumentCount{Function: string(topMostOperator), Count: argc}
+				}
+				operatorStack.Pop()
+				outputQueue.Enqueue(rpnToken{token: topMostOperator, call: true, argc: argc})
+			} else if argc != 1 {
+				return nil, ErrMisplacedComma{}
+			}
+		} else if name, isCall := np.functionCall(token); isCall {
+			operatorStack.Push(name)
 		} else {
-			outputQueue.Enqueue(token)
+			outputQueue.Enqueue(rpnToken{token: token})
+			operand = true
 		}
 
+		prevOperand = operand || token == RPAREN
 		prevToken = token
 	}
 
@@ -287,41 +668,84 @@ func (np *Nparser) Run() (float64, error) {
 		if err != nil {
 			break
 		}
-		outputQueue.Enqueue(topMostOperator)
+		if topMostOperator == LPAREN {
+			return nil, ErrMismatchedParentheses{}
+		}
+		outputQueue.Enqueue(rpnToken{token: topMostOperator})
 	}
 
-	return np.eval(outputQueue)
+	return outputQueue, nil
 }
 
-func (np *Nparser) eval(rpn *nqueue.NQueue[Token]) (float64, error) {
+func (np *Nparser) eval(rpn *nqueue.NQueue[rpnToken]) (float64, error) {
 	stack := nstack.New[float64]()
+	steps := 0
 
 	for {
-		token, err := rpn.Dequeue()
+		item, err := rpn.Dequeue()
 		if err != nil {
 			break
 		}
+		token := item.token
+
+		steps++
+		if err := np.limits.check(LimitSteps, steps, np.limits.MaxSteps); err != nil {
+			return 0, err
+		}
+		if np.ctx != nil && steps%contextCheckInterval == 0 {
+			if err := np.ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
 
-		if token == UMINUS {
+		if np.isUnary(token) {
 			a, err := stack.Pop()
 			if err != nil {
-				return 0, ErrUnaryMinusMissingOperand{}
+				if token == UMINUS {
+					return 0, ErrUnaryMinusMissingOperand{}
+				}
+				return 0, ErrNotEnoughOperands{}
+			}
+			res, err := applyUnaryOperator(token, a)
+			if err != nil {
+				return 0, err
+			}
+			name := string(token)
+			if token == SQRT {
+				name = "sqrt"
+			}
+			if err := np.policy.check(name, res, 0); err != nil {
+				return 0, err
 			}
-			stack.Push(-a)
+			stack.Push(res)
 			continue
 		}
 
-		if fn, isFunc := functionList[string(token)]; isFunc {
-			arity := fn.arity
-			args := make([]float64, arity)
-			for i := arity - 1; i >= 0; i-- {
+		if item.call {
+			fn := functionList[string(token)]
+			args := make([]float64, item.argc, item.argc+len(fn.defaults))
+			for i := item.argc - 1; i >= 0; i-- {
 				arg, err := stack.Pop()
 				if err != nil {
 					return 0, ErrNotEnoughOperandsForFunction{Function: string(token)}
 				}
 				args[i] = arg
 			}
-			result := fn.fn(args...)
+			if missing := fn.arity + len(fn.defaults) - item.argc; missing > 0 {
+				args = append(args, fn.defaults[len(fn.defaults)-missing:]...)
+			}
+			var result float64
+			if fn.randFn != nil {
+				if np.rand == nil {
+					np.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+				}
+				result = fn.randFn(np.rand, args...)
+			} else {
+				result = fn.fn(args...)
+			}
+			if err := np.policy.check(string(token), result, 0); err != nil {
+				return 0, err
+			}
 			stack.Push(result)
 			continue
 		}
@@ -333,23 +757,19 @@ func (np *Nparser) eval(rpn *nqueue.NQueue[Token]) (float64, error) {
 			if err1 != nil || err2 != nil {
 				return 0, ErrNotEnoughOperands{}
 			}
-
-			var res float64
-			switch token {
-			case PLUS:
-				res = a + b
-			case MINUS:
-				res = a - b
-			case MUL:
-				res = a * b
-			case DIV:
-				res = a / b
-			case POW:
-				res = math.Pow(a, b)
-			default:
-				return 0, ErrUnsupportedOperator{Operator: string(token)}
+			if token == POW {
+				if err := np.limits.checkExponent(b); err != nil {
+					return 0, err
+				}
 			}
 
+			res, err := applyOperator(token, a, b)
+			if err != nil {
+				return 0, err
+			}
+			if err := np.policy.check(string(token), res, b); err != nil {
+				return 0, err
+			}
 			stack.Push(res)
 		} else {
 			num, err := strconv.ParseFloat(string(token), 64)
@@ -371,5 +791,85 @@ func (np *Nparser) eval(rpn *nqueue.NQueue[Token]) (float64, error) {
 		return 0, ErrEmptyStack{}
 	}
 
-	return result, nil
+	return np.policy.apply(result), nil
+}
+
+// applyUnaryOperator applies a prefix operator
+func applyUnaryOperator(operator Token, a float64) (float64, error) {
+	switch operator {
+	case UMINUS:
+		return -a, nil
+	case SQRT:
+		return math.Sqrt(a), nil
+	case NOT:
+		x, err := toInteger(operator, a)
+		if err != nil {
+			return 0, err
+		}
+		return float64(^x), nil
+	}
+	return 0, ErrUnsupportedOperator{Operator: string(operator)}
+}
+
+// applyOperator applies a binary operator
+func applyOperator(operator Token, a, b float64) (float64, error) {
+	switch operator {
+	case PLUS:
+		return a + b, nil
+	case MINUS:
+		return a - b, nil
+	case MUL:
+		return a * b, nil
+	case DIV:
+		return a / b, nil
+	case POW:
+		return math.Pow(a, b), nil
+	case AND, OR, XOR, SHL, SHR:
+		return applyIntegerOperator(operator, a, b)
+	}
+	return 0, ErrUnsupportedOperator{Operator: string(operator)}
+}
+
+// toInteger converts an operand of an integer operator, failing for values that are not integers
+func toInteger(operator Token, value float64) (int64, error) {
+	if value != math.Trunc(value) || value < math.MinInt64 || value >= -math.MinInt64 {
+		return 0, ErrNonIntegerOperand{Operator: string(operator), Value: value}
+	}
+	return int64(value), nil
+}
+
+// applyIntegerOperator applies a binary integer operator
+func applyIntegerOperator(operator Token, a, b float64) (float64, error) {
+	x, err := toInteger(operator, a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toInteger(operator, b)
+	if err != nil {
+		return 0, err
+	}
+
+	switch operator {
+	case AND:
+		return float64(x & y), nil
+	case OR:
+		return float64(x | y), nil
+	case XOR:
+		return float64(x ^ y), nil
+	case SHL, SHR:
+		if y < 0 {
+			return 0, ErrNegativeShift{Count: y}
+		}
+		if y >= 64 {
+			return 0, ErrShiftOutOfRange{Count: y}
+		}
+		if operator == SHR {
+			return float64(x >> y), nil
+		}
+		if x<<y>>y != x {
+			return 0, ErrIntegerOverflow{Operator: SHL}
+		}
+		return float64(x << y), nil
+	}
+	return 0, ErrUnsupportedOperator{Operator: string(operator)}
 }
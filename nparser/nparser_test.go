@@ -93,3 +93,364 @@ func TestWithMultipleFunctions(t *testing.T) {
 	}
 	os.Unsetenv("LOG_LEVEL")
 }
+
+func TestWithFunctionFollowedByOperator(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	nparser := New("sin(0) + 1")
+	result, err := nparser.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 1 {
+		t.Errorf("expected 1, got %f", result)
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithSeededRandomFunctions(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	expression := "rand() + randint(1, 6) + uniform(2, 3) + normal(10, 2)"
+	first := New(expression)
+	first.SetSeed(42)
+	a, err := first.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := New(expression)
+	second.SetSeed(42)
+	b, err := second.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("expected equal results for the same seed, got %f and %f", a, b)
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithRandint(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	for seed := int64(0); seed < 100; seed++ {
+		nparser := New("randint(1, 6)")
+		nparser.SetSeed(seed)
+		result, err := nparser.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result < 1 || result > 6 || result != math.Trunc(result) {
+			t.Errorf("expected an integer in [1, 6], got %f", result)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestRandintRanges(t *testing.T) {
+	for _, expression := range []string{"randint(0, 1/0)", "randint(-1/0, 0)", "randint(-1/0, 1/0)", "randint(1/0, 1/0)", "randint(0, 2^63)", "randint(-2^62, 2^62)", "randint(3, 1)"} {
+		result, err := New(expression).Run()
+		if err != nil {
+			t.Fatalf("%s: %v", expression, err)
+		}
+		if !math.IsNaN(result) {
+			t.Errorf("%s: expected NaN, got %f", expression, result)
+		}
+	}
+
+	result, err := New("randint(0, 2^62)").Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result < 0 || result > 0x1p62 {
+		t.Errorf("expected an integer in [0, 2^62], got %f", result)
+	}
+}
+
+func TestWithFinancialFunctions(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"pmt(rate / 12, 10, 10000)", -1037.03},
+		{"pmt(rate / 12, 10, 10000, 0, 1)", -1030.16},
+		{"fv(0.06 / 12, 10, -200, -500, 1)", 2581.40},
+		{"pv(rate / 12, 12 * 20, 500)", -59777.15},
+		{"npv(0.1, -10000, 3000, 4200, 6800)", 1188.44},
+		{"irr(-70000, 12000, 15000, 18000, 21000, 26000) * 100", 8.66},
+		{"irrguess(0.1, -70000, 12000, 15000, 18000, 21000, 26000) * 100", 8.66},
+		// cash flows with two rates of return, the guess choosing the one found
+		{"irr(-100, 230, -132) * 100", 10},
+		{"irrguess(0.3, -100, 230, -132) * 100", 20},
+		{"rate(48, -200, 8000) * 100", 0.77},
+	}
+	for _, test := range tests {
+		nparser := New(test.expression)
+		nparser.SetVariable("rate", 0.08)
+		result, err := nparser.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(result-test.expected) > 0.005 {
+			t.Errorf("%s: expected %f, got %f", test.expression, test.expected, result)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithVariadicFunctions(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	nparser := New("max(2, 3, 4, 5) + min(1, -2)")
+	result, err := nparser.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 3 {
+		t.Errorf("expected 3, got %f", result)
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestVariadicMaxMin(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"max(1, 2)", 2},
+		{"min(1, 2)", 1},
+		{"max(x, 7, -y, 3)", 7},
+		{"max(3, 7, x, -y)", 7},
+		{"min(x, 7, -y, 3)", -4},
+		{"min(-y, 3, x, 7)", -4},
+		{"max(x, x, x)", 5},
+		{"max(1, 2, 0 / 0)", math.NaN()},
+		{"min(0 / 0, 1, 2)", math.NaN()},
+	}
+	variables := Variables{"x": 5, "y": 4}
+	for _, test := range tests {
+		np := New(test.expression)
+		for name, value := range variables {
+			np.SetVariable(name, value)
+		}
+		result, err := np.Run()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if result != test.expected && !(math.IsNaN(result) && math.IsNaN(test.expected)) {
+			t.Errorf("%s: expected %v from Run, got %v", test.expression, test.expected, result)
+		}
+		for _, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := program.Run(variables)
+			if err != nil || result != test.expected && !(math.IsNaN(result) && math.IsNaN(test.expected)) {
+				t.Errorf("%s: expected %v from backend %d, got %v (%v)", test.expression, test.expected, backend, result, err)
+			}
+		}
+	}
+
+	for _, expression := range []string{"max()", "max(1)", "min(1)"} {
+		if _, err := New(expression).Run(); err == nil {
+			t.Errorf("%s: expected an error for fewer than two arguments", expression)
+		}
+	}
+}
+
+func TestWithWrongArgumentCount(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	for _, expression := range []string{"sin(1, 2)", "pmt(0.1, 10)", "max(1)", "(1, 2)"} {
+		nparser := New(expression)
+		_, err := nparser.Run()
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", expression)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithAssociativity(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"1 - 2 - 3", -4},
+		{"8 - 3 - 2", 3},
+		{"8 / 4 / 2", 1},
+		{"64 / 4 / 2", 8},
+		{"2 * 3 / 6", 1},
+		{"2 ^ 3 ^ 2", 512},
+		{"2 ^ -1", 0.5},
+		{"-2 ^ 2", -4},
+	}
+	for _, test := range tests {
+		result, err := New(test.expression).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %f, got %f", test.expression, test.expected, result)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithIntegerMode(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"12 & 10", 8},
+		{"12 | 3", 15},
+		{"12 xor 10", 6},
+		{"~0", -1},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 << 2 + 1", 8},
+		{"1 | 2 xor 3 & 1", 3},
+		{"reg & ~(1 << bit)", 0xF7},
+		{"2 ^ 3 & 7", 0},
+		{"16 >> 2 >> 1", 2},
+		{"1 << 2 << 3", 32},
+	}
+	for _, test := range tests {
+		nparser := New(test.expression)
+		nparser.SetIntegerMode(true)
+		nparser.SetVariable("reg", 0xFF)
+		nparser.SetVariable("bit", 3)
+		result, err := nparser.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %f, got %f", test.expression, test.expected, result)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithIntegerOperatorErrors(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	for _, expression := range []string{"12 & 10", "12 | 10", "12 xor 10", "~12", "1 << 2", "4 >> 1"} {
+		_, err := New(expression).Run()
+		if _, ok := err.(ErrIntegerModeRequired); !ok {
+			t.Errorf("%s: expected ErrIntegerModeRequired, got %v", expression, err)
+		}
+	}
+
+	nparser := New("1.5 & 1")
+	nparser.SetIntegerMode(true)
+	_, err := nparser.Run()
+	if _, ok := err.(ErrNonIntegerOperand); !ok {
+		t.Errorf("expected ErrNonIntegerOperand, got %v", err)
+	}
+
+	nparser = New("1 << -1")
+	nparser.SetIntegerMode(true)
+	_, err = nparser.Run()
+	if _, ok := err.(ErrNegativeShift); !ok {
+		t.Errorf("expected ErrNegativeShift, got %v", err)
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithShiftBounds(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   float64
+		err        error
+	}{
+		{"1 << 62", 1 << 62, nil},
+		{"-1 << 63", math.MinInt64, nil},
+		{"-8 >> 63", -1, nil},
+		{"8 >> 63", 0, nil},
+		{"1 << 63", 0, ErrIntegerOverflow{Operator: SHL}},
+		{"3 << 62", 0, ErrIntegerOverflow{Operator: SHL}},
+		{"1 << 64", 0, ErrShiftOutOfRange{Count: 64}},
+		{"-8 >> 64", 0, ErrShiftOutOfRange{Count: 64}},
+		{"1 >> 100", 0, ErrShiftOutOfRange{Count: 100}},
+	}
+	for _, test := range tests {
+		nparser := New(test.expression)
+		nparser.SetIntegerMode(true)
+		result, err := nparser.Run()
+		if err != test.err || result != test.expected {
+			t.Errorf("%s: expected %v (%v), got %v (%v)", test.expression, test.expected, test.err, result, err)
+		}
+	}
+}
+
+func TestWithMathNotation(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"6 × 7", 42},
+		{"84 ÷ 2", 42},
+		{"50 − 8", 42},
+		{"√16 + 38", 42},
+		{"√(x²)", 3},
+		{"2³", 8},
+		{"2⁻¹", 0.5},
+		{"2 × π", 2 * math.Pi},
+		{"1 +\t2\n+\u00a03", 6},
+		{"θ × 2", 6},
+	}
+	for _, test := range tests {
+		nparser := New(test.expression)
+		nparser.SetVariable("x", -3)
+		nparser.SetVariable("θ", 3)
+		result, err := nparser.Run()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %f, got %f", test.expression, test.expected, result)
+		}
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestWithUnexpectedUnicodeCharacter(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "DEBUG")
+	_, err := New("2 ≠ 3").Run()
+	if e, ok := err.(ErrUnexpectedChar); !ok || e.Char != '≠' {
+		t.Errorf("expected ErrUnexpectedChar for ≠, got %v", err)
+	}
+	os.Unsetenv("LOG_LEVEL")
+}
+
+func TestIsVariableName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"x", true},
+		{"mask.low", true},
+		{"x2", true},
+		{"été", true},
+		{"rate", true},
+		{"2x", false},
+		{".x", false},
+		{"a b", false},
+		{"x-y", false},
+		{"xor", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if IsVariableName(test.name) != test.expected {
+			t.Errorf("%q: expected %v", test.name, test.expected)
+		}
+		if !test.expected {
+			continue
+		}
+		// the parser reads the name as that variable
+		tree, err := New(test.name).Parse()
+		if err != nil || tree.Kind != VariableNode || tree.Name != test.name {
+			t.Errorf("%q: expected the parser to read a variable, got %v (%v)", test.name, tree, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package nparser
+
+import "math"
+
+// Policy decides what an evaluation does with numbers that are not finite,
+// such as the infinity of 1/0 or the NaN of log(-1)
+type Policy int
+
+const (
+	// PropagatePolicy returns infinities and NaN as floating point arithmetic gives them
+	PropagatePolicy Policy = iota
+
+	// ErrorPolicy fails the evaluation at the first operation giving a number that is
+	// not finite, with an ErrDivisionByZero, ErrDomain or ErrOverflow
+	ErrorPolicy
+
+	// SubstitutePolicy replaces a result that is not finite with a substitute value
+	SubstitutePolicy
+)
+
+// resultPolicy is a policy along with its substitute value
+type resultPolicy struct {
+	policy     Policy
+	substitute float64
+}
+
+// SetPolicy sets the policy for numbers that are not finite, for Run and for the programs
+// built by Compile. The substitute value is only used by SubstitutePolicy.
+func (np *Nparser) SetPolicy(policy Policy, substitute float64) {
+	np.policy = resultPolicy{policy: policy, substitute: substitute}
+}
+
+// check fails if an operator or function gave a number that is not finite under ErrorPolicy.
+// divisor is the right operand of a division, telling divisions by zero apart.
+func (rp resultPolicy) check(name string, result, divisor float64) error {
+	if rp.policy != ErrorPolicy {
+		return nil
+	}
+	return checkFinite(name, result, divisor)
+}
+
+// checkFinite fails if an operator or function gave a number that is not finite
+func checkFinite(name string, result, divisor float64) error {
+	switch {
+	case !math.IsNaN(result) && !math.IsInf(result, 0):
+		return nil
+	case name == DIV && divisor == 0:
+		return ErrDivisionByZero{}
+	case math.IsNaN(result):
+		return ErrDomain{Function: name}
+	}
+	return ErrOverflow{Function: name}
+}
+
+// apply substitutes a result that is not finite under SubstitutePolicy
+func (rp resultPolicy) apply(result float64) float64 {
+	if rp.policy == SubstitutePolicy && (math.IsNaN(result) || math.IsInf(result, 0)) {
+		return rp.substitute
+	}
+	return result
+}
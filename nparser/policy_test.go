@@ -0,0 +1,80 @@
+package nparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	tests := []struct {
+		expression string
+		variables  Variables
+		expected   error
+	}{
+		{"1 / x", Variables{"x": 0}, ErrDivisionByZero{}},
+		{"x / (1 - 1)", Variables{"x": 0}, ErrDivisionByZero{}},
+		{"log(x) + 1", Variables{"x": -1}, ErrDomain{Function: "log"}},
+		{"2 * sqrt(x)", Variables{"x": -1}, ErrDomain{Function: "sqrt"}},
+		{"√x", Variables{"x": -4}, ErrDomain{Function: "sqrt"}},
+		{"10 ^ x", Variables{"x": 400}, ErrOverflow{Function: POW}},
+		{"log(x)", Variables{"x": 0}, ErrOverflow{Function: "log"}},
+	}
+
+	for _, test := range tests {
+		np := New(test.expression)
+		for name, value := range test.variables {
+			np.SetVariable(name, value)
+		}
+		np.SetPolicy(ErrorPolicy, 0)
+		if _, err := np.Run(); !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected Run to fail with %v, got %v", test.expression, test.expected, err)
+		}
+
+		for _, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatalf("%s: %v", test.expression, err)
+			}
+			if _, err := program.Run(test.variables); !errors.Is(err, test.expected) {
+				t.Errorf("%s: expected backend %d to fail with %v, got %v", test.expression, backend, test.expected, err)
+			}
+			_, err = program.EvalColumns(map[string][]float64{"x": {test.variables["x"]}})
+			var rowErrors ErrRows
+			if !errors.As(err, &rowErrors) || rowErrors.Errors[0].Err != test.expected {
+				t.Errorf("%s: expected the row to fail with %v, got %v", test.expression, test.expected, err)
+			}
+		}
+	}
+}
+
+func TestPropagateAndSubstitutePolicies(t *testing.T) {
+	np := New("1 / x + log(y)")
+	np.SetVariable("x", 0)
+	np.SetVariable("y", 1)
+	variables := Variables{"x": 0, "y": 1}
+
+	if result, err := np.Run(); err != nil || !math.IsInf(result, 1) {
+		t.Errorf("expected +Inf by default, got %v (%v)", result, err)
+	}
+
+	np.SetPolicy(SubstitutePolicy, -1)
+	if result, err := np.Run(); err != nil || result != -1 {
+		t.Errorf("expected the substitute -1 from Run, got %v (%v)", result, err)
+	}
+	for _, backend := range backends {
+		np.SetBackend(backend)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := program.Run(variables); err != nil || result != -1 {
+			t.Errorf("expected the substitute -1 from backend %d, got %v (%v)", backend, result, err)
+		}
+		results, err := program.EvalColumns(map[string][]float64{"x": {0, 1}, "y": {1, 1}})
+		if err != nil || results[0] != -1 || results[1] != 1 {
+			t.Errorf("expected columns [-1 1], got %v (%v)", results, err)
+		}
+	}
+}
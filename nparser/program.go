@@ -0,0 +1,520 @@
+package nparser
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// opcode is an instruction of the bytecode virtual machine
+type opcode uint8
+
+const (
+	opConst opcode = iota
+	opLoad
+	opNeg
+	opNot
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opPow
+	opAnd
+	opOr
+	opXor
+	opShl
+	opShr
+	opCall
+	opCallRand
+)
+
+// binaryOpcodes maps binary operators to their instructions
+var binaryOpcodes = map[string]opcode{
+	PLUS:  opAdd,
+	MINUS: opSub,
+	MUL:   opMul,
+	DIV:   opDiv,
+	POW:   opPow,
+	AND:   opAnd,
+	OR:    opOr,
+	XOR:   opXor,
+	SHL:   opShl,
+	SHR:   opShr,
+}
+
+// operators maps the instructions of operators back to their operators, for error reporting
+var operators = [...]Token{
+	opNeg: UMINUS,
+	opNot: NOT,
+	opAdd: PLUS,
+	opSub: MINUS,
+	opMul: MUL,
+	opDiv: DIV,
+	opPow: POW,
+	opAnd: AND,
+	opOr:  OR,
+	opXor: XOR,
+	opShl: SHL,
+	opShr: SHR,
+}
+
+// instruction is a single bytecode instruction
+type instruction struct {
+	op opcode
+	// arg is the slot of a variable or the index of a call
+	arg int
+	// value is the value of a constant
+	value float64
+}
+
+// call is a call site: the function and the number of values it takes from the stack
+type call struct {
+	name   string
+	fn     Function
+	randFn RandFunction
+	// derivative is the derivative of a function of a single argument, for gradient evaluations
+	derivative func(x float64) float64
+	argc       int
+}
+
+// Program is a compiled expression, ready to be evaluated many times by its backend.
+// A Program is safe for concurrent use.
+type Program struct {
+	expression Expression
+	root       *Node
+	// variables are the variable names, indexed by slot
+	variables []string
+	slots     map[string]int
+	code      []instruction
+	calls     []call
+	stackSize int
+	backend   Backend
+	closure   closure
+	// closureNodes counts the operators and calls compiled to closures, placing the checks of the context
+	closureNodes int
+	limits       Limits
+	policy       resultPolicy
+	envs         sync.Pool
+}
+
+// Env holds the state of a single evaluation of a Program. An Env can be
+// reused across evaluations but not shared between goroutines.
+type Env struct {
+	slots []float64
+	stack []float64
+	rand  *rand.Rand
+	// err is the first error raised while evaluating closures
+	err error
+	// gradient holds the buffers of gradient evaluations, created by the first one
+	gradient *gradientState
+	// ctx is the context of an evaluation by EvalContext, checked every contextCheckInterval steps
+	ctx context.Context
+}
+
+// Compile parses the expression and compiles it to a Program
+func (np *Nparser) Compile() (*Program, error) {
+	root, err := np.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return np.compileTree(root)
+}
+
+// Compile parses an expression and compiles it to a Program
+func Compile(expression string) (*Program, error) {
+	return New(expression).Compile()
+}
+
+// compileTree compiles an expression tree to a Program
+func (np *Nparser) compileTree(root *Node) (*Program, error) {
+	p := &Program{
+		expression: np.expression,
+		backend:    np.backend,
+		limits:     np.limits,
+		policy:     np.policy,
+	}
+	if err := p.compile(root); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compile compiles an expression tree for the backend, limits and policy set on the program
+func (p *Program) compile(root *Node) error {
+	p.root = root
+	p.variables = nil
+	p.slots = make(map[string]int)
+	p.code = nil
+	p.calls = nil
+	p.stackSize = 0
+	p.closure = nil
+	p.closureNodes = 0
+
+	if err := p.limits.checkConstantExponents(root); err != nil {
+		return err
+	}
+	folded := fold(root)
+	c := &compiler{program: p}
+	if err := c.emit(folded); err != nil {
+		return err
+	}
+	// the program performs every instruction once per evaluation
+	if err := p.limits.check(LimitSteps, len(p.code), p.limits.MaxSteps); err != nil {
+		return err
+	}
+	if p.backend == ClosureBackend {
+		var err error
+		p.closure, err = p.compileClosure(folded, 0)
+		if err != nil {
+			return err
+		}
+	}
+	// the environments of a previous compilation may not fit
+	p.envs = sync.Pool{New: func() any {
+		return p.NewEnv()
+	}}
+	return nil
+}
+
+// compiler emits the bytecode of an expression tree, tracking the stack depth it needs
+type compiler struct {
+	program *Program
+	depth   int
+}
+
+// push records the change in stack depth caused by the last instruction
+func (c *compiler) push(n int) {
+	c.depth += n
+	if c.depth > c.program.stackSize {
+		c.program.stackSize = c.depth
+	}
+}
+
+// emit appends the instructions evaluating the node, in post-order
+func (c *compiler) emit(node *Node) error {
+	p := c.program
+
+	switch node.Kind {
+	case NumberNode:
+		p.code = append(p.code, instruction{op: opConst, value: node.Value})
+		c.push(1)
+	case VariableNode:
+		slot, ok := p.slots[node.Name]
+		if !ok {
+			slot = len(p.variables)
+			p.slots[node.Name] = slot
+			p.variables = append(p.variables, node.Name)
+		}
+		p.code = append(p.code, instruction{op: opLoad, arg: slot})
+		c.push(1)
+	case OperatorNode:
+		for _, arg := range node.Args {
+			if err := c.emit(arg); err != nil {
+				return err
+			}
+		}
+		if len(node.Args) == 1 {
+			switch node.Name {
+			case UMINUS:
+				p.code = append(p.code, instruction{op: opNeg})
+			case NOT:
+				p.code = append(p.code, instruction{op: opNot})
+			default:
+				return ErrUnsupportedOperator{Operator: node.Name}
+			}
+			return nil
+		}
+		op, ok := binaryOpcodes[node.Name]
+		if !ok || len(node.Args) != 2 {
+			return ErrUnsupportedOperator{Operator: node.Name}
+		}
+		p.code = append(p.code, instruction{op: op})
+		c.push(-1)
+	case CallNode:
+		desc, ok := functionList[node.Name]
+		if !ok {
+			return ErrUnknownFunction{Function: node.Name}
+		}
+		if !desc.accepts(len(node.Args)) {
+			return ErrWrongArgumentCount{Function: node.Name, Count: len(node.Args)}
+		}
+		for _, arg := range node.Args {
+			if err := c.emit(arg); err != nil {
+				return err
+			}
+		}
+		argc := len(node.Args)
+		// the omitted optional arguments are pushed as constants
+		for _, value := range desc.defaults[min(argc-desc.arity, len(desc.defaults)):] {
+			p.code = append(p.code, instruction{op: opConst, value: value})
+			c.push(1)
+			argc++
+		}
+		op := opCall
+		if desc.randFn != nil {
+			op = opCallRand
+		}
+		p.code = append(p.code, instruction{op: op, arg: len(p.calls)})
+		p.calls = append(p.calls, call{name: node.Name, fn: desc.fn, randFn: desc.randFn, derivative: derivatives[node.Name], argc: argc})
+		c.push(1 - argc)
+	}
+
+	return nil
+}
+
+// fold evaluates the subtrees whose operands are all constants. Calls to random
+// functions are never folded since their result changes between evaluations, and
+// neither are subtrees whose evaluation fails or does not give a finite number.
+func fold(node *Node) *Node {
+	if node.Kind == NumberNode || node.Kind == VariableNode {
+		return node
+	}
+
+	folded := &Node{Kind: node.Kind, Name: node.Name, Args: make([]*Node, len(node.Args))}
+	values := make([]float64, len(node.Args))
+	constant := true
+	for i, arg := range node.Args {
+		folded.Args[i] = fold(arg)
+		values[i] = folded.Args[i].Value
+		constant = constant && folded.Args[i].Kind == NumberNode
+	}
+	if !constant || folded.isVolatile() {
+		return folded
+	}
+
+	value, err := applyNode(folded, values)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return folded
+	}
+
+	return &Node{Kind: NumberNode, Value: value}
+}
+
+// applyNode applies the operator or function of the node to the values of its arguments.
+// It cannot apply random functions.
+func applyNode(node *Node, values []float64) (float64, error) {
+	switch {
+	case node.Kind == CallNode:
+		desc, ok := functionList[node.Name]
+		if !ok || desc.fn == nil {
+			return 0, ErrUnknownFunction{Function: node.Name}
+		}
+		if !desc.accepts(len(values)) {
+			return 0, ErrWrongArgumentCount{Function: node.Name, Count: len(values)}
+		}
+		values = append(values, desc.defaults[min(len(values)-desc.arity, len(desc.defaults)):]...)
+		return desc.fn(values...), nil
+	case node.Kind == OperatorNode && len(values) == 1:
+		return applyUnaryOperator(Token(node.Name), values[0])
+	case node.Kind == OperatorNode && len(values) == 2:
+		return applyOperator(Token(node.Name), values[0], values[1])
+	}
+	return 0, ErrUnsupportedOperator{Operator: node.Name}
+}
+
+// NewEnv creates an environment for evaluating the program, with every variable set to zero
+func (p *Program) NewEnv() *Env {
+	return &Env{
+		slots: make([]float64, len(p.variables)),
+		stack: make([]float64, p.stackSize),
+	}
+}
+
+// SetVariables assigns the values of the program's variables, failing if one is missing
+func (p *Program) SetVariables(env *Env, variables Variables) error {
+	for slot, name := range p.variables {
+		value, ok := variables[name]
+		if !ok {
+			return ErrUndefinedVariable{Variable: name}
+		}
+		env.slots[slot] = value
+	}
+	return nil
+}
+
+// SetSlots assigns the values of the program's variables by slot, in the order given by Variables
+func (p *Program) SetSlots(env *Env, values []float64) error {
+	if len(values) != len(p.variables) {
+		return ErrSlotCount{Expected: len(p.variables), Got: len(values)}
+	}
+	copy(env.slots, values)
+	return nil
+}
+
+// Variables returns the names of the program's variables, in slot order
+func (p *Program) Variables() []string {
+	return append([]string(nil), p.variables...)
+}
+
+// Functions returns the names of the functions the expression calls, in order of first appearance
+func (p *Program) Functions() []string {
+	return p.root.functions(nil)
+}
+
+// Missing returns the variables of the program that have no value in the given variables, in slot order
+func (p *Program) Missing(variables Variables) []string {
+	var missing []string
+	for _, name := range p.variables {
+		if _, ok := variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Slot returns the slot of a variable, and whether the program uses the variable
+func (p *Program) Slot(name string) (int, bool) {
+	slot, ok := p.slots[name]
+	return slot, ok
+}
+
+// SetSeed seeds the random source of the environment, making its evaluations reproducible
+func (env *Env) SetSeed(seed int64) {
+	env.rand = rand.New(rand.NewSource(seed))
+}
+
+// SetRand sets the random source of the environment
+func (env *Env) SetRand(r *rand.Rand) {
+	env.rand = r
+}
+
+// random returns the random source of the environment, creating one if needed
+func (env *Env) random() *rand.Rand {
+	if env.rand == nil {
+		env.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return env.rand
+}
+
+// Run evaluates the program with the given variables
+func (p *Program) Run(variables Variables) (float64, error) {
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+
+	if err := p.SetVariables(env, variables); err != nil {
+		return 0, err
+	}
+	return p.Eval(env)
+}
+
+// RunSlots evaluates the program with the values of its variables given by slot,
+// in the order given by Variables. Unlike Run, it does not look up any names.
+func (p *Program) RunSlots(values []float64) (float64, error) {
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+
+	if err := p.SetSlots(env, values); err != nil {
+		return 0, err
+	}
+	return p.Eval(env)
+}
+
+// Expression returns the expression the program was compiled from
+func (p *Program) Expression() string {
+	return string(p.expression)
+}
+
+// Backend returns the backend evaluating the program
+func (p *Program) Backend() Backend {
+	return p.backend
+}
+
+// Eval evaluates the program in an environment created by its NewEnv
+func (p *Program) Eval(env *Env) (float64, error) {
+	if p.closure != nil {
+		env.err = nil
+		result := p.closure(env)
+		if env.err != nil {
+			return 0, env.err
+		}
+		return p.policy.apply(result), nil
+	}
+
+	stack := env.stack
+	slots := env.slots
+	sp := 0
+	checked := p.policy.policy == ErrorPolicy
+
+	for i := range p.code {
+		if env.ctx != nil && i%contextCheckInterval == contextCheckInterval-1 {
+			if err := env.ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
+		ins := &p.code[i]
+		switch ins.op {
+		case opConst:
+			stack[sp] = ins.value
+			sp++
+		case opLoad:
+			stack[sp] = slots[ins.arg]
+			sp++
+		case opNeg:
+			stack[sp-1] = -stack[sp-1]
+		case opNot:
+			x, err := toInteger(NOT, stack[sp-1])
+			if err != nil {
+				return 0, err
+			}
+			stack[sp-1] = float64(^x)
+		case opAdd:
+			sp--
+			stack[sp-1] += stack[sp]
+		case opSub:
+			sp--
+			stack[sp-1] -= stack[sp]
+		case opMul:
+			sp--
+			stack[sp-1] *= stack[sp]
+		case opDiv:
+			sp--
+			stack[sp-1] /= stack[sp]
+		case opPow:
+			sp--
+			if err := p.limits.checkExponent(stack[sp]); err != nil {
+				return 0, err
+			}
+			stack[sp-1] = math.Pow(stack[sp-1], stack[sp])
+		case opAnd, opOr, opXor, opShl, opShr:
+			sp--
+			res, err := applyIntegerOperator(operators[ins.op], stack[sp-1], stack[sp])
+			if err != nil {
+				return 0, err
+			}
+			stack[sp-1] = res
+		case opCall:
+			c := &p.calls[ins.arg]
+			sp -= c.argc
+			stack[sp] = c.fn(stack[sp : sp+c.argc]...)
+			sp++
+		case opCallRand:
+			c := &p.calls[ins.arg]
+			sp -= c.argc
+			stack[sp] = c.randFn(env.random(), stack[sp:sp+c.argc]...)
+			sp++
+		}
+
+		if checked && ins.op > opLoad {
+			// the divisor of a division is left just above its result
+			divisor := math.NaN()
+			if ins.op == opDiv {
+				divisor = stack[sp]
+			}
+			if err := checkFinite(p.instructionName(ins), stack[sp-1], divisor); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return p.policy.apply(stack[0]), nil
+}
+
+// instructionName is the name of the operator or function of an instruction, for error reporting
+func (p *Program) instructionName(ins *instruction) string {
+	if ins.op == opCall || ins.op == opCallRand {
+		return p.calls[ins.arg].name
+	}
+	return string(operators[ins.op])
+}
@@ -0,0 +1,345 @@
+package nparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+// evalCases are evaluated by the interpreter and by every compiled backend
+var evalCases = []struct {
+	expression  string
+	variables   Variables
+	integerMode bool
+	expected    float64
+}{
+	{"2 + 2", nil, false, 4},
+	{"x + y", Variables{"x": 2, "y": 2}, false, 4},
+	{"sin(x)", Variables{"x": math.Pi / 2}, false, 1},
+	{"2 + 2 * (3 + 4) / 5", nil, false, 4.8},
+	{"sin(max(2, 333))", nil, false, math.Sin(333)},
+	{"sin(0) + 1", nil, false, 1},
+	{"1 - 2 - 3", nil, false, -4},
+	{"2 ^ 3 ^ 2", nil, false, 512},
+	{"-2 ^ 2", nil, false, -4},
+	{"2 ^ -x", Variables{"x": 1}, false, 0.5},
+	{"-(x - 3) * -x", Variables{"x": 5}, false, 10},
+	{"max(x, 3, y, 5) + min(1, -2)", Variables{"x": 2, "y": 9}, false, 7},
+	{"pmt(rate, 10, 10000) + pmt(rate, 10, 10000, 0, 0)", Variables{"rate": 0.1}, false, -3254.907897650231},
+	{"npv(0.1, x, 3000, 4200, 6800)", Variables{"x": -10000}, false, 1188.4434123352207},
+	{"√16 × π ÷ π", nil, false, 4},
+	{"x² + x³", Variables{"x": 3}, false, 36},
+	{"reg & ~(1 << bit) | 1", Variables{"reg": 0xFE, "bit": 3}, true, 0xF7},
+	{"12 xor x", Variables{"x": 10}, true, 6},
+	{"log(x) + log10(100) + log2(8)", Variables{"x": 1}, false, 5},
+}
+
+// backends are all the backends a Program can be compiled for
+var backends = []Backend{BytecodeBackend, ClosureBackend}
+
+// errorCases fail to parse or to evaluate
+var errorCases = []struct {
+	expression  string
+	variables   Variables
+	integerMode bool
+}{
+	{"(2 + 2 * 3 + 4) / 5)", nil, false},
+	{"(2 + 2", nil, false},
+	{"x + y", Variables{"x": 1}, false},
+	{"2 *", nil, false},
+	{"sin(1, 2)", nil, false},
+	{"1.5 & x", Variables{"x": 1}, true},
+	{"x >> -1", Variables{"x": 1}, true},
+	{"1 & 1", nil, false},
+}
+
+func TestProgramMatchesRun(t *testing.T) {
+	for _, test := range evalCases {
+		np := New(test.expression)
+		np.SetIntegerMode(test.integerMode)
+		for name, value := range test.variables {
+			np.SetVariable(name, value)
+		}
+		expected, err := np.Run()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if math.Abs(expected-test.expected) > 1e-9 {
+			t.Errorf("%s: Run gave %v, expected %v", test.expression, expected, test.expected)
+		}
+
+		for _, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatalf("%s: %v", test.expression, err)
+			}
+			result, err := program.Run(test.variables)
+			if err != nil {
+				t.Fatalf("%s: %v", test.expression, err)
+			}
+			if result != expected {
+				t.Errorf("%s: Program.Run with backend %d gave %v, Run gave %v", test.expression, backend, result, expected)
+			}
+		}
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	for _, test := range errorCases {
+		np := New(test.expression)
+		np.SetIntegerMode(test.integerMode)
+		for name, value := range test.variables {
+			np.SetVariable(name, value)
+		}
+		if _, err := np.Run(); err == nil {
+			t.Errorf("%s: expected Run to fail", test.expression)
+		}
+
+		for _, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err == nil {
+				_, err = program.Run(test.variables)
+			}
+			if err == nil {
+				t.Errorf("%s: expected Program with backend %d to fail", test.expression, backend)
+			}
+		}
+	}
+}
+
+// TestProgramRejectsExtraOperands pins that Run keeps evaluating operands left over by the
+// operators to the last of them, as it always has, while the compiler rejects them
+func TestProgramRejectsExtraOperands(t *testing.T) {
+	result, err := New("2 3").Run()
+	if err != nil || result != 3 {
+		t.Errorf("expected Run to give 3, got %f and %v", result, err)
+	}
+	if _, err := Compile("2 3"); !errors.Is(err, ErrTooManyOperands{}) {
+		t.Errorf("expected ErrTooManyOperands, got %v", err)
+	}
+}
+
+func TestProgramRunSlots(t *testing.T) {
+	program, err := Compile("y * 10 + x - y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables := program.Variables()
+	if len(variables) != 2 || variables[0] != "y" || variables[1] != "x" {
+		t.Fatalf("expected variables [y x], got %v", variables)
+	}
+	if slot, ok := program.Slot("x"); !ok || slot != 1 {
+		t.Errorf("expected x in slot 1, got %d", slot)
+	}
+	result, err := program.RunSlots([]float64{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := program.Run(Variables{"x": 3, "y": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != expected || result != 21 {
+		t.Errorf("expected 21 from both RunSlots and Run, got %v and %v", result, expected)
+	}
+	if _, err := program.RunSlots([]float64{2}); err == nil {
+		t.Error("expected an error for a missing value")
+	}
+}
+
+func TestProgramIntrospection(t *testing.T) {
+	program, err := Compile("max(x, sin(0)) + √y - sin(x) + pmt(rate, 10, 1000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	functions := program.Functions()
+	if !slices.Equal(functions, []string{"max", "sin", "sqrt", "pmt"}) {
+		t.Errorf("expected functions [max sin sqrt pmt], got %v", functions)
+	}
+	variables := program.Variables()
+	if !slices.Equal(variables, []string{"x", "y", "rate"}) {
+		t.Errorf("expected variables [x y rate], got %v", variables)
+	}
+	missing := program.Missing(Variables{"y": 1})
+	if !slices.Equal(missing, []string{"x", "rate"}) {
+		t.Errorf("expected missing variables [x rate], got %v", missing)
+	}
+	if missing := program.Missing(Variables{"x": 1, "y": 1, "rate": 0.1}); len(missing) != 0 {
+		t.Errorf("expected no missing variables, got %v", missing)
+	}
+}
+
+func TestProgramFoldsConstantsButNotRandomFunctions(t *testing.T) {
+	program, err := Compile("2 * 3 + x + rand() * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.code) != 7 {
+		t.Errorf("expected 7 instructions, got %d", len(program.code))
+	}
+}
+
+func TestProgramWithSeed(t *testing.T) {
+	np := New("normal(x, 1) + randint(1, 6)")
+	results := make([]float64, len(backends))
+	for i, backend := range backends {
+		np.SetBackend(backend)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := program.NewEnv()
+		env.SetSeed(42)
+		if err := program.SetVariables(env, Variables{"x": 10}); err != nil {
+			t.Fatal(err)
+		}
+		results[i], err = program.Eval(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if results[0] != results[1] {
+		t.Errorf("expected equal results for the same seed on every backend, got %v", results)
+	}
+}
+
+func TestProgramBackendsEvaluateOperandsInOrder(t *testing.T) {
+	tests := []struct {
+		expression string
+		policy     Policy
+	}{
+		{"uniform(0, 1) ^ rand()", PropagatePolicy},
+		{"rand() - normal(0, 1) ^ randint(1, 3)", PropagatePolicy},
+		{"log(-1) ^ (1 / 0)", ErrorPolicy},
+		{"sqrt(-1) ^ (1 / 0) + 1 / 0", ErrorPolicy},
+	}
+	for _, test := range tests {
+		np := New(test.expression)
+		np.SetLimits(DefaultLimits)
+		np.SetPolicy(test.policy, 0)
+		results := make([]float64, len(backends))
+		errs := make([]error, len(backends))
+		for i, backend := range backends {
+			np.SetBackend(backend)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := program.NewEnv()
+			env.SetSeed(7)
+			results[i], errs[i] = program.Eval(env)
+		}
+		if results[0] != results[1] && !(math.IsNaN(results[0]) && math.IsNaN(results[1])) {
+			t.Errorf("%s: expected equal results on every backend, got %v", test.expression, results)
+		}
+		if fmt.Sprint(errs[0]) != fmt.Sprint(errs[1]) {
+			t.Errorf("%s: expected the same first error on every backend, got %v", test.expression, errs)
+		}
+	}
+}
+
+func TestProgramDoesNotAllocate(t *testing.T) {
+	np := New(benchmarkExpression)
+	for _, backend := range backends {
+		np.SetBackend(backend)
+		program, err := np.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := program.Run(benchmarkVariables); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("expected no allocations per evaluation with backend %d, got %v", backend, allocs)
+		}
+	}
+}
+
+const benchmarkExpression = "x * sin(y) + max(2, x, y) ^ 2 / (y + 1) - sqrt(x * y) + 3 * 4"
+
+var benchmarkVariables = Variables{"x": 3, "y": 4}
+
+func BenchmarkRun(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		np := New(benchmarkExpression)
+		for name, value := range benchmarkVariables {
+			np.SetVariable(name, value)
+		}
+		if _, err := np.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Run(benchmarkVariables); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramRunSlots(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	values := make([]float64, len(program.Variables()))
+	for name, value := range benchmarkVariables {
+		slot, _ := program.Slot(name)
+		values[slot] = value
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.RunSlots(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEval(b *testing.B) {
+	program, err := Compile(benchmarkExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := program.NewEnv()
+	if err := program.SetVariables(env, benchmarkVariables); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Eval(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramRunClosure(b *testing.B) {
+	np := New(benchmarkExpression)
+	np.SetBackend(ClosureBackend)
+	program, err := np.Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Run(benchmarkVariables); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
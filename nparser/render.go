@@ -0,0 +1,241 @@
+package nparser
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// latexOperators maps operators to their LaTeX notation, division and powers aside
+var latexOperators = map[string]string{
+	PLUS:   "+",
+	MINUS:  "-",
+	MUL:    `\cdot`,
+	UMINUS: "-",
+	AND:    `\mathbin{\&}`,
+	OR:     `\mathbin{|}`,
+	XOR:    `\oplus`,
+	SHL:    `\ll`,
+	SHR:    `\gg`,
+	NOT:    `\sim `,
+}
+
+// latexFunctions maps functions to their LaTeX commands, the others are rendered as operator names
+var latexFunctions = map[string]string{
+	"sin":   `\sin`,
+	"cos":   `\cos`,
+	"tan":   `\tan`,
+	"cosec": `\csc`,
+	"sec":   `\sec`,
+	"cot":   `\cot`,
+	"log":   `\ln`,
+	"log10": `\log_{10}`,
+	"log2":  `\log_{2}`,
+	"max":   `\max`,
+	"min":   `\min`,
+}
+
+// mathmlOperators maps operators to their MathML notation, division and powers aside
+var mathmlOperators = map[string]string{
+	PLUS:   "+",
+	MINUS:  "−",
+	MUL:    "·",
+	UMINUS: "−",
+	AND:    "&amp;",
+	OR:     "|",
+	XOR:    "⊕",
+	SHL:    "≪",
+	SHR:    "≫",
+	NOT:    "~",
+}
+
+// mathmlFunctions maps functions to the MathML of their names, the others are rendered as identifiers
+var mathmlFunctions = map[string]string{
+	"cosec": "<mi>csc</mi>",
+	"log":   "<mi>ln</mi>",
+	"log10": "<msub><mi>log</mi><mn>10</mn></msub>",
+	"log2":  "<msub><mi>log</mi><mn>2</mn></msub>",
+}
+
+// LaTeX renders the tree in LaTeX, with fractions for divisions and superscripts for powers
+func (node *Node) LaTeX() string {
+	var b strings.Builder
+	node.latex(&b)
+	return b.String()
+}
+
+// latex writes the LaTeX of the tree
+func (node *Node) latex(b *strings.Builder) {
+	switch node.Kind {
+	case NumberNode:
+		b.WriteString(formatNumber(node.Value))
+	case VariableNode:
+		if utf8.RuneCountInString(node.Name) == 1 {
+			b.WriteString(node.Name)
+		} else {
+			b.WriteString(`\mathrm{` + node.Name + "}")
+		}
+	case CallNode:
+		if node.Name == "sqrt" {
+			b.WriteString(`\sqrt{`)
+			node.Args[0].latex(b)
+			b.WriteString("}")
+			return
+		}
+		if command, ok := latexFunctions[node.Name]; ok {
+			b.WriteString(command)
+		} else {
+			b.WriteString(`\operatorname{` + node.Name + "}")
+		}
+		b.WriteString(`\left(`)
+		for i, arg := range node.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			arg.latex(b)
+		}
+		b.WriteString(`\right)`)
+	case OperatorNode:
+		switch {
+		case node.Name == DIV:
+			b.WriteString(`\frac{`)
+			node.Args[0].latex(b)
+			b.WriteString("}{")
+			node.Args[1].latex(b)
+			b.WriteString("}")
+		case node.Name == POW:
+			b.WriteString("{")
+			node.latexOperand(b, 0)
+			b.WriteString("}^{")
+			node.Args[1].latex(b)
+			b.WriteString("}")
+		case len(node.Args) == 1:
+			b.WriteString(latexOperators[node.Name])
+			node.latexOperand(b, 0)
+		default:
+			node.latexOperand(b, 0)
+			b.WriteString(" " + latexOperators[node.Name] + " ")
+			node.latexOperand(b, 1)
+		}
+	}
+}
+
+// latexOperand writes the LaTeX of an operand, in parentheses if needed
+func (node *Node) latexOperand(b *strings.Builder, i int) {
+	if !node.renderParentheses(i) {
+		node.Args[i].latex(b)
+		return
+	}
+	b.WriteString(`\left(`)
+	node.Args[i].latex(b)
+	b.WriteString(`\right)`)
+}
+
+// MathML renders the tree in Presentation MathML, with fractions for divisions and superscripts for powers
+func (node *Node) MathML() string {
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	node.mathml(&b)
+	b.WriteString("</math>")
+	return b.String()
+}
+
+// mathml writes the MathML of the tree
+func (node *Node) mathml(b *strings.Builder) {
+	switch node.Kind {
+	case NumberNode:
+		if node.Value < 0 {
+			b.WriteString("<mrow><mo>" + mathmlOperators[UMINUS] + "</mo><mn>" + formatNumber(-node.Value) + "</mn></mrow>")
+		} else {
+			b.WriteString("<mn>" + formatNumber(node.Value) + "</mn>")
+		}
+	case VariableNode:
+		b.WriteString("<mi>" + html.EscapeString(node.Name) + "</mi>")
+	case CallNode:
+		if node.Name == "sqrt" {
+			b.WriteString("<msqrt>")
+			node.Args[0].mathml(b)
+			b.WriteString("</msqrt>")
+			return
+		}
+		b.WriteString("<mrow>")
+		if name, ok := mathmlFunctions[node.Name]; ok {
+			b.WriteString(name)
+		} else {
+			b.WriteString("<mi>" + node.Name + "</mi>")
+		}
+		// the invisible function application operator
+		b.WriteString("<mo>&#x2061;</mo><mrow><mo>(</mo>")
+		for i, arg := range node.Args {
+			if i > 0 {
+				b.WriteString("<mo>,</mo>")
+			}
+			arg.mathml(b)
+		}
+		b.WriteString("<mo>)</mo></mrow></mrow>")
+	case OperatorNode:
+		switch {
+		case node.Name == DIV:
+			b.WriteString("<mfrac><mrow>")
+			node.Args[0].mathml(b)
+			b.WriteString("</mrow><mrow>")
+			node.Args[1].mathml(b)
+			b.WriteString("</mrow></mfrac>")
+		case node.Name == POW:
+			b.WriteString("<msup><mrow>")
+			node.mathmlOperand(b, 0)
+			b.WriteString("</mrow><mrow>")
+			node.Args[1].mathml(b)
+			b.WriteString("</mrow></msup>")
+		case len(node.Args) == 1:
+			b.WriteString("<mrow><mo>" + mathmlOperators[node.Name] + "</mo>")
+			node.mathmlOperand(b, 0)
+			b.WriteString("</mrow>")
+		default:
+			b.WriteString("<mrow>")
+			node.mathmlOperand(b, 0)
+			b.WriteString("<mo>" + mathmlOperators[node.Name] + "</mo>")
+			node.mathmlOperand(b, 1)
+			b.WriteString("</mrow>")
+		}
+	}
+}
+
+// mathmlOperand writes the MathML of an operand, in parentheses if needed
+func (node *Node) mathmlOperand(b *strings.Builder, i int) {
+	if !node.renderParentheses(i) {
+		node.Args[i].mathml(b)
+		return
+	}
+	b.WriteString("<mrow><mo>(</mo>")
+	node.Args[i].mathml(b)
+	b.WriteString("<mo>)</mo></mrow>")
+}
+
+// renderParentheses checks if an operand of an operator needs parentheses when rendered.
+// Unlike in text, fractions and exponents group their operands, and prefix operators on
+// the right are put in parentheses to be read more easily.
+func (node *Node) renderParentheses(i int) bool {
+	arg := node.Args[i]
+	precedence := arg.precedence()
+	if arg.Kind == OperatorNode && arg.Name == DIV {
+		precedence = atomPrecedence
+	}
+
+	switch {
+	case node.Name == DIV || node.Name == POW && i == 1:
+		return false
+	case node.Name == POW:
+		// a base is anything but an atom, or it would read as being raised itself
+		return arg.Kind == OperatorNode || arg.isPrefix()
+	case len(node.Args) == 1:
+		return precedence < node.precedence()
+	case i == 1 && arg.isPrefix():
+		return true
+	}
+	leftAssociative := isLeftAssociative[Operator(node.Name)]
+	if i == 0 {
+		return precedence < node.precedence() || precedence == node.precedence() && !leftAssociative
+	}
+	return precedence < node.precedence() || precedence == node.precedence() && leftAssociative
+}
@@ -0,0 +1,56 @@
+package nparser
+
+import "testing"
+
+func TestLaTeX(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"x + 2 * y", `x + 2 \cdot y`},
+		{"(x + 1) / (y - 1)", `\frac{x + 1}{y - 1}`},
+		{"2 * x / 3", `\frac{2 \cdot x}{3}`},
+		{"x / 2 * 3", `\frac{x}{2} \cdot 3`},
+		{"(x + 1) ^ (2 * n)", `{\left(x + 1\right)}^{2 \cdot n}`},
+		{"-x ^ 2", `-{x}^{2}`},
+		{"(-x) ^ 2", `{\left(-x\right)}^{2}`},
+		{"x - -y", `x - \left(-y\right)`},
+		{"sqrt(x + 1) + √y", `\sqrt{x + 1} + \sqrt{y}`},
+		{"sin(x) ^ 2 + log(y)", `{\sin\left(x\right)}^{2} + \ln\left(y\right)`},
+		{"pmt(rate, 10, 1000)", `\operatorname{pmt}\left(\mathrm{rate}, 10, 1000\right)`},
+	}
+
+	for _, test := range tests {
+		tree, err := New(test.expression).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if latex := tree.LaTeX(); latex != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.expression, test.expected, latex)
+		}
+	}
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"x + 2", "<mrow><mi>x</mi><mo>+</mo><mn>2</mn></mrow>"},
+		{"(x - 1) / 2", "<mfrac><mrow><mrow><mi>x</mi><mo>−</mo><mn>1</mn></mrow></mrow><mrow><mn>2</mn></mrow></mfrac>"},
+		{"(x * y) ^ 2", "<msup><mrow><mrow><mo>(</mo><mrow><mi>x</mi><mo>·</mo><mi>y</mi></mrow><mo>)</mo></mrow></mrow><mrow><mn>2</mn></mrow></msup>"},
+		{"√x", "<msqrt><mi>x</mi></msqrt>"},
+		{"log10(x)", "<mrow><msub><mi>log</mi><mn>10</mn></msub><mo>&#x2061;</mo><mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow></mrow>"},
+	}
+
+	for _, test := range tests {
+		tree, err := New(test.expression).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		expected := `<math xmlns="http://www.w3.org/1998/Math/MathML">` + test.expected + "</math>"
+		if mathml := tree.MathML(); mathml != expected {
+			t.Errorf("%s: expected %s, got %s", test.expression, expected, mathml)
+		}
+	}
+}
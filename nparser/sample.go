@@ -0,0 +1,72 @@
+package nparser
+
+import (
+	"context"
+	"math"
+)
+
+// Point is a point of a sampled function
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Sample evaluates the program at count evenly spaced values of a variable from one bound
+// to the other inclusive, with the other variables fixed. The points at which the evaluation
+// fails with a domain or arithmetic error have a NaN value, like those outside of the domain
+// of the function, while other errors, such as exceeded limits, fail the sampling.
+func (p *Program) Sample(variable string, from, to float64, count int, fixed Variables) ([]Point, error) {
+	return p.SampleContext(context.Background(), variable, from, to, count, fixed)
+}
+
+// SampleContext samples the program like Sample, giving up with the error of the context once it is done
+func (p *Program) SampleContext(ctx context.Context, variable string, from, to float64, count int, fixed Variables) ([]Point, error) {
+	if count < 2 {
+		return nil, ErrSampleCount{Count: count}
+	}
+
+	env := p.envs.Get().(*Env)
+	defer p.envs.Put(env)
+	slot, ok := p.slots[variable]
+	for i, name := range p.variables {
+		if i == slot && ok {
+			continue
+		}
+		value, defined := fixed[name]
+		if !defined {
+			return nil, ErrUndefinedVariable{Variable: name}
+		}
+		env.slots[i] = value
+	}
+
+	points := make([]Point, count)
+	step := (to - from) / float64(count-1)
+	for i := range points {
+		x := from + float64(i)*step
+		if i == count-1 {
+			x = to
+		}
+		if ok {
+			env.slots[slot] = x
+		}
+		y, err := p.EvalContext(ctx, env)
+		if err != nil {
+			if !isGap(err) {
+				return nil, err
+			}
+			y = math.NaN()
+		}
+		points[i] = Point{X: x, Y: y}
+	}
+	return points, nil
+}
+
+// isGap checks if an error of an evaluation is a domain or arithmetic error, leaving a gap in
+// a sampled function rather than failing the sampling
+func isGap(err error) bool {
+	switch err.(type) {
+	case ErrDivisionByZero, ErrDomain, ErrOverflow, ErrNonIntegerOperand, ErrNegativeShift, ErrShiftOutOfRange, ErrIntegerOverflow:
+		return true
+	}
+	return false
+}
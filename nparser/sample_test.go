@@ -0,0 +1,97 @@
+package nparser
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestProgramSample(t *testing.T) {
+	program, err := Compile("a * x ^ 2 + log(x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := program.Sample("x", -1, 1, 5, Variables{"a": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xs := []float64{-1, -0.5, 0, 0.5, 1}
+	if len(points) != len(xs) {
+		t.Fatalf("expected %d points, got %d", len(xs), len(points))
+	}
+	for i, point := range points {
+		expected := 2*xs[i]*xs[i] + math.Log(xs[i])
+		if point.X != xs[i] || point.Y != expected && !(math.IsNaN(point.Y) && math.IsNaN(expected)) {
+			t.Errorf("point %d: expected (%v, %v), got (%v, %v)", i, xs[i], expected, point.X, point.Y)
+		}
+	}
+}
+
+func TestProgramSampleErrors(t *testing.T) {
+	np := New("1 / x + y")
+	np.SetPolicy(ErrorPolicy, 0)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := program.Sample("x", 0, 1, 1, Variables{"y": 1}); !errors.Is(err, ErrSampleCount{Count: 1}) {
+		t.Errorf("expected ErrSampleCount, got %v", err)
+	}
+	if _, err := program.Sample("x", 0, 1, 10, nil); !errors.Is(err, ErrUndefinedVariable{Variable: "y"}) {
+		t.Errorf("expected ErrUndefinedVariable, got %v", err)
+	}
+
+	// domain and arithmetic errors are gaps
+	points, err := program.Sample("x", 0, 1, 3, Variables{"y": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(points[0].Y) || points[2].Y != 2 {
+		t.Errorf("expected a gap at 0, got %v", points)
+	}
+
+	// a variable the program does not reference gives a constant function
+	points, err = program.Sample("z", 0, 1, 2, Variables{"x": 1, "y": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points[0].Y != 2 || points[1].Y != 2 {
+		t.Errorf("expected a constant function, got %v", points)
+	}
+}
+
+func TestProgramSampleFailures(t *testing.T) {
+	np := New("2 ^ x")
+	np.SetLimits(Limits{MaxExponent: 10})
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ErrLimitExceeded{Limit: LimitExponent, Max: 10}
+	if _, err := program.Sample("x", 0, 20, 5, nil); !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+
+	np = New("x & 1")
+	np.SetIntegerMode(true)
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := program.Sample("x", 0, 1, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points[0].Y != 0 || !math.IsNaN(points[1].Y) || points[2].Y != 1 {
+		t.Errorf("expected a gap at 0.5, got %v", points)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := program.SampleContext(ctx, "x", 0, 1, 3, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package nparser
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"slices"
+)
+
+// serializationVersion is the version of the serialized form of programs written by Marshal
+const serializationVersion = 1
+
+// binaryMagic starts the binary form of programs
+const binaryMagic = "NUM"
+
+// maxSerializedDepth bounds the nesting of the trees read back, so that loading cannot exhaust the stack
+const maxSerializedDepth = 10000
+
+// kindNames are the names of the kinds of nodes in the JSON form
+var kindNames = [...]string{
+	NumberNode:   "number",
+	VariableNode: "variable",
+	OperatorNode: "operator",
+	CallNode:     "call",
+}
+
+// backendNames are the names of the backends in the JSON form
+var backendNames = [...]string{
+	BytecodeBackend: "bytecode",
+	ClosureBackend:  "closure",
+}
+
+// policyNames are the names of the policies in the JSON form
+var policyNames = [...]string{
+	PropagatePolicy:  "propagate",
+	ErrorPolicy:      "error",
+	SubstitutePolicy: "substitute",
+}
+
+// programJSON is the JSON form of a program
+type programJSON struct {
+	Version    int      `json:"version"`
+	Expression string   `json:"expression"`
+	Variables  []string `json:"variables"`
+	Functions  []string `json:"functions"`
+	Backend    string   `json:"backend"`
+	Policy     string   `json:"policy"`
+	Substitute float64  `json:"substitute,omitempty"`
+	Limits     Limits   `json:"limits"`
+	Tree       *Node    `json:"tree"`
+}
+
+// nodeJSON is the JSON form of a node
+type nodeJSON struct {
+	Kind  string  `json:"kind"`
+	Value float64 `json:"value,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Args  []*Node `json:"args,omitempty"`
+}
+
+// MarshalJSON encodes the program as versioned JSON, holding its tree as parsed along with its
+// expression, variables, functions, backend, policy and limits
+func (p *Program) MarshalJSON() ([]byte, error) {
+	return json.Marshal(programJSON{
+		Version:    serializationVersion,
+		Expression: string(p.expression),
+		Variables:  append([]string{}, p.variables...),
+		Functions:  append([]string{}, p.Functions()...),
+		Backend:    backendNames[p.backend],
+		Policy:     policyNames[p.policy.policy],
+		Substitute: p.policy.substitute,
+		Limits:     p.limits,
+		Tree:       p.root,
+	})
+}
+
+// UnmarshalJSON decodes a program encoded by MarshalJSON, validating and compiling its tree
+func (p *Program) UnmarshalJSON(data []byte) error {
+	var decoded programJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return ErrInvalidProgram{Reason: err.Error()}
+	}
+	if decoded.Version != serializationVersion {
+		return ErrUnsupportedVersion{Version: decoded.Version}
+	}
+	if decoded.Tree == nil {
+		return ErrInvalidProgram{Reason: "missing tree"}
+	}
+
+	backend := slices.Index(backendNames[:], decoded.Backend)
+	if backend < 0 {
+		return ErrInvalidProgram{Reason: "unknown backend " + decoded.Backend}
+	}
+	policy := slices.Index(policyNames[:], decoded.Policy)
+	if policy < 0 {
+		return ErrInvalidProgram{Reason: "unknown policy " + decoded.Policy}
+	}
+
+	loaded := &Program{
+		expression: Expression(decoded.Expression),
+		backend:    Backend(backend),
+		policy:     resultPolicy{policy: Policy(policy), substitute: decoded.Substitute},
+		limits:     decoded.Limits,
+	}
+	if err := loaded.load(decoded.Tree); err != nil {
+		return err
+	}
+	if decoded.Variables != nil && !slices.Equal(decoded.Variables, loaded.variables) {
+		return ErrInvalidProgram{Reason: "variables do not match the tree"}
+	}
+	return p.replace(loaded)
+}
+
+// MarshalJSON encodes the node as JSON
+func (node *Node) MarshalJSON() ([]byte, error) {
+	if int(node.Kind) >= len(kindNames) {
+		return nil, ErrInvalidProgram{Reason: "unknown kind of node"}
+	}
+	return json.Marshal(nodeJSON{Kind: kindNames[node.Kind], Value: node.Value, Name: node.Name, Args: node.Args})
+}
+
+// UnmarshalJSON decodes a node encoded by MarshalJSON, without validating it
+func (node *Node) UnmarshalJSON(data []byte) error {
+	var decoded nodeJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	kind := slices.Index(kindNames[:], decoded.Kind)
+	if kind < 0 {
+		return ErrInvalidProgram{Reason: "unknown kind of node " + decoded.Kind}
+	}
+	*node = Node{Kind: NodeKind(kind), Value: decoded.Value, Name: decoded.Name, Args: decoded.Args}
+	return nil
+}
+
+// MarshalBinary encodes the program in a compact binary form holding the same as its JSON form
+func (p *Program) MarshalBinary() ([]byte, error) {
+	data := []byte(binaryMagic)
+	data = append(data, serializationVersion, byte(p.backend), byte(p.policy.policy))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(p.policy.substitute))
+	for _, limit := range []int{p.limits.MaxTokens, p.limits.MaxDepth, p.limits.MaxCallDepth, p.limits.MaxSteps} {
+		data = binary.AppendUvarint(data, uint64(limit))
+	}
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(p.limits.MaxExponent))
+	data = appendString(data, string(p.expression))
+	return appendNode(data, p.root), nil
+}
+
+// appendString appends a string prefixed with its length
+func appendString(data []byte, s string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(s)))
+	return append(data, s...)
+}
+
+// appendNode appends a tree in pre-order
+func appendNode(data []byte, node *Node) []byte {
+	data = append(data, byte(node.Kind))
+	switch node.Kind {
+	case NumberNode:
+		return binary.LittleEndian.AppendUint64(data, math.Float64bits(node.Value))
+	case VariableNode:
+		return appendString(data, node.Name)
+	}
+	data = appendString(data, node.Name)
+	data = binary.AppendUvarint(data, uint64(len(node.Args)))
+	for _, arg := range node.Args {
+		data = appendNode(data, arg)
+	}
+	return data
+}
+
+// UnmarshalBinary decodes a program encoded by MarshalBinary, validating and compiling its tree
+func (p *Program) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return ErrInvalidProgram{Reason: "not a serialized program"}
+	}
+	d := &decoder{data: data[len(binaryMagic):]}
+	if version := d.byte(); version != serializationVersion {
+		return ErrUnsupportedVersion{Version: int(version)}
+	}
+
+	backend := Backend(d.byte())
+	policy := Policy(d.byte())
+	substitute := d.float()
+	var limits Limits
+	for _, limit := range []*int{&limits.MaxTokens, &limits.MaxDepth, &limits.MaxCallDepth, &limits.MaxSteps} {
+		*limit = int(d.uvarint())
+	}
+	limits.MaxExponent = d.float()
+	expression := d.string()
+	root := d.node(0)
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return ErrInvalidProgram{Reason: "trailing data"}
+	}
+	if int(backend) >= len(backendNames) {
+		return ErrInvalidProgram{Reason: "unknown backend"}
+	}
+	if int(policy) >= len(policyNames) {
+		return ErrInvalidProgram{Reason: "unknown policy"}
+	}
+
+	loaded := &Program{
+		expression: Expression(expression),
+		backend:    backend,
+		policy:     resultPolicy{policy: policy, substitute: substitute},
+		limits:     limits,
+	}
+	if err := loaded.load(root); err != nil {
+		return err
+	}
+	return p.replace(loaded)
+}
+
+// decoder reads the binary form of programs, recording the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// fail records an error and returns nothing more to read
+func (d *decoder) fail(reason string) {
+	if d.err == nil {
+		d.err = ErrInvalidProgram{Reason: reason}
+	}
+	d.data = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) < 1 {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return value
+}
+
+func (d *decoder) float() float64 {
+	if len(d.data) < 8 {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	value := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return value
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail("unexpected end of data")
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+// node reads a tree in pre-order
+func (d *decoder) node(depth int) *Node {
+	if depth > maxSerializedDepth {
+		d.fail("tree too deep")
+		return nil
+	}
+	node := &Node{Kind: NodeKind(d.byte())}
+	switch node.Kind {
+	case NumberNode:
+		node.Value = d.float()
+	case VariableNode:
+		node.Name = d.string()
+	case OperatorNode, CallNode:
+		node.Name = d.string()
+		argc := d.uvarint()
+		// every argument takes a byte at least
+		if argc > uint64(len(d.data)) {
+			d.fail("too many arguments")
+			return nil
+		}
+		node.Args = make([]*Node, argc)
+		for i := range node.Args {
+			node.Args[i] = d.node(depth + 1)
+		}
+	default:
+		d.fail("unknown kind of node")
+	}
+	return node
+}
+
+// load validates a decoded tree and compiles it into the program
+func (p *Program) load(root *Node) error {
+	if err := validateNode(root, 0); err != nil {
+		return err
+	}
+	if err := p.limits.checkTree(root); err != nil {
+		return err
+	}
+	return p.compile(root)
+}
+
+// replace makes the program a copy of one loaded successfully, compiling its tree again since
+// a program holds a pool of environments that cannot be copied
+func (p *Program) replace(loaded *Program) error {
+	p.expression = loaded.expression
+	p.backend = loaded.backend
+	p.policy = loaded.policy
+	p.limits = loaded.limits
+	return p.compile(loaded.root)
+}
+
+// validateNode checks that a decoded tree is one the parser could build
+func validateNode(node *Node, depth int) error {
+	if node == nil {
+		return ErrInvalidProgram{Reason: "missing node"}
+	}
+	if depth > maxSerializedDepth {
+		return ErrInvalidProgram{Reason: "tree too deep"}
+	}
+
+	switch node.Kind {
+	case NumberNode:
+		if math.IsNaN(node.Value) || math.IsInf(node.Value, 0) || len(node.Args) != 0 {
+			return ErrInvalidProgram{Reason: "invalid number"}
+		}
+		return nil
+	case VariableNode:
+		if !IsVariableName(node.Name) || len(node.Args) != 0 {
+			return ErrInvalidProgram{Reason: "invalid variable " + node.Name}
+		}
+		return nil
+	case OperatorNode:
+		_, isBinary := binaryOpcodes[node.Name]
+		isUnary := node.Name == UMINUS || node.Name == NOT
+		if !(isBinary && len(node.Args) == 2 || isUnary && len(node.Args) == 1) {
+			return ErrInvalidProgram{Reason: "invalid operator " + node.Name}
+		}
+	case CallNode:
+		desc, ok := functionList[node.Name]
+		if !ok {
+			return ErrUnknownFunction{Function: node.Name}
+		}
+		if !desc.accepts(len(node.Args)) {
+			return ErrWrongArgumentCount{Function: node.Name, Count: len(node.Args)}
+		}
+	default:
+		return ErrInvalidProgram{Reason: "unknown kind of node"}
+	}
+
+	for _, arg := range node.Args {
+		if err := validateNode(arg, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
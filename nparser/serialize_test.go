@@ -0,0 +1,230 @@
+package nparser
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProgramSerializationRoundTrip(t *testing.T) {
+	vars := Variables{"x": 2, "y": -3, "rate": 0.05}
+	expressions := []string{
+		"x + y * 2",
+		"-x ^ 2 + sqrt(x * 8)",
+		"max(x, y, 4) / min(x, 1)",
+		"log10(rate * 1000) - log2(x)",
+		"sin(x) ^ 2 + cos(x) ^ 2",
+		"1 + 2 * 3",
+	}
+
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		for _, expression := range expressions {
+			np := New(expression)
+			np.SetBackend(backend)
+			np.SetPolicy(SubstitutePolicy, -1)
+			np.SetLimits(DefaultLimits)
+			program, err := np.Compile()
+			if err != nil {
+				t.Fatalf("%s: %v", expression, err)
+			}
+			expected, err := program.Run(vars)
+			if err != nil {
+				t.Fatalf("%s: %v", expression, err)
+			}
+
+			encodings := map[string]func() (*Program, error){
+				"json": func() (*Program, error) {
+					data, err := json.Marshal(program)
+					if err != nil {
+						return nil, err
+					}
+					loaded := &Program{}
+					return loaded, json.Unmarshal(data, loaded)
+				},
+				"binary": func() (*Program, error) {
+					data, err := program.MarshalBinary()
+					if err != nil {
+						return nil, err
+					}
+					loaded := &Program{}
+					return loaded, loaded.UnmarshalBinary(data)
+				},
+			}
+			for name, roundTrip := range encodings {
+				loaded, err := roundTrip()
+				if err != nil {
+					t.Fatalf("%s (%s): %v", expression, name, err)
+				}
+				if loaded.Expression() != program.Expression() || loaded.backend != backend ||
+					loaded.policy != program.policy || loaded.limits != program.limits {
+					t.Errorf("%s (%s): settings not preserved", expression, name)
+				}
+				if !reflect.DeepEqual(loaded.Variables(), program.Variables()) {
+					t.Errorf("%s (%s): expected variables %v, got %v", expression, name, program.Variables(), loaded.Variables())
+				}
+				result, err := loaded.Run(vars)
+				if err != nil {
+					t.Fatalf("%s (%s): %v", expression, name, err)
+				}
+				if result != expected && !(math.IsNaN(result) && math.IsNaN(expected)) {
+					t.Errorf("%s (%s): expected %v, got %v", expression, name, expected, result)
+				}
+			}
+		}
+	}
+}
+
+func TestProgramMarshalJSON(t *testing.T) {
+	program, err := Compile("x * 2 + log(y)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["version"] != float64(1) || decoded["expression"] != "x * 2 + log(y)" || decoded["backend"] != "bytecode" {
+		t.Errorf("unexpected encoding %s", data)
+	}
+	tree := decoded["tree"].(map[string]any)
+	if tree["kind"] != "operator" || tree["name"] != PLUS {
+		t.Errorf("unexpected tree %v", tree)
+	}
+}
+
+func TestProgramUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected error
+	}{
+		{`{"version": 2, "tree": {"kind": "number", "value": 1}}`, ErrUnsupportedVersion{Version: 2}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate"}`, ErrInvalidProgram{Reason: "missing tree"}},
+		{`{"version": 1, "backend": "jit", "policy": "propagate", "tree": {"kind": "number"}}`, ErrInvalidProgram{Reason: "unknown backend jit"}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate", "tree": {"kind": "call", "name": "exec", "args": []}}`, ErrUnknownFunction{Function: "exec"}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate", "tree": {"kind": "call", "name": "sin"}}`, ErrWrongArgumentCount{Function: "sin", Count: 0}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate", "tree": {"kind": "operator", "name": "+", "args": [{"kind": "number"}]}}`, ErrInvalidProgram{Reason: "invalid operator +"}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate", "tree": {"kind": "variable", "name": "1x"}}`, ErrInvalidProgram{Reason: "invalid variable 1x"}},
+		{`{"version": 1, "backend": "bytecode", "policy": "propagate", "variables": ["y"], "tree": {"kind": "variable", "name": "x"}}`, ErrInvalidProgram{Reason: "variables do not match the tree"}},
+	}
+
+	for _, test := range tests {
+		err := (&Program{}).UnmarshalJSON([]byte(test.data))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.data, test.expected, err)
+		}
+	}
+
+	var invalid ErrInvalidProgram
+	if err := (&Program{}).UnmarshalJSON([]byte(`{"version": 1, "tree": {"kind": "matrix"}}`)); !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidProgram for an unknown kind of node, got %v", err)
+	}
+}
+
+func TestProgramUnmarshalBinaryErrors(t *testing.T) {
+	program, err := Compile("x + max(y, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versioned := append([]byte{}, data...)
+	versioned[len(binaryMagic)] = 9
+	if err := (&Program{}).UnmarshalBinary(versioned); !errors.Is(err, ErrUnsupportedVersion{Version: 9}) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	var invalid ErrInvalidProgram
+	for _, corrupt := range [][]byte{
+		nil,
+		[]byte("JSON"),
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+	} {
+		if err := (&Program{}).UnmarshalBinary(corrupt); !errors.As(err, &invalid) {
+			t.Errorf("expected ErrInvalidProgram for %q, got %v", corrupt, err)
+		}
+	}
+
+	// a deep tree of negations is rejected without exhausting the stack
+	deep := append([]byte{}, data[:len(data)-len(appendNode(nil, program.root))]...)
+	for range maxSerializedDepth + 2 {
+		deep = append(deep, byte(OperatorNode))
+		deep = appendString(deep, UMINUS)
+		deep = append(deep, 1)
+	}
+	if err := (&Program{}).UnmarshalBinary(deep); !errors.As(err, &invalid) || !strings.Contains(err.Error(), "deep") {
+		t.Errorf("expected a tree too deep, got %v", err)
+	}
+}
+
+func TestProgramUnmarshalChecksLimits(t *testing.T) {
+	program, err := Compile("sin(cos(tan(x))) + (1 + (2 + (3 + x)))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryData, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		limits string
+		limit  string
+		// offset is the offset of the limit in the binary form, after the version, backend, policy and substitute
+		offset int
+	}{
+		{`{"maxTokens": 10}`, LimitTokens, 0},
+		{`{"maxDepth": 2}`, LimitDepth, 1},
+		{`{"maxCallDepth": 2}`, LimitCallDepth, 2},
+	}
+	for _, test := range tests {
+		crafted := strings.Replace(string(data), `"limits":{}`, `"limits":`+test.limits, 1)
+		loaded, err := Compile("x + 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = loaded.UnmarshalJSON([]byte(crafted))
+		var exceeded ErrLimitExceeded
+		if !errors.As(err, &exceeded) || exceeded.Limit != test.limit {
+			t.Errorf("%s: expected the %s limit to be exceeded, got %v", test.limits, test.limit, err)
+		}
+		// a program that fails to load is left as it was
+		if result, err := loaded.Run(Variables{"x": 1}); err != nil || result != 2 || loaded.Expression() != "x + 1" {
+			t.Errorf("%s: expected the program to be unchanged, got %v (%v)", test.limits, loaded.Expression(), err)
+		}
+
+		craftedBinary := append([]byte{}, binaryData...)
+		craftedBinary[len(binaryMagic)+3+8+test.offset] = 2
+		err = loaded.UnmarshalBinary(craftedBinary)
+		if !errors.As(err, &exceeded) || exceeded.Limit != test.limit {
+			t.Errorf("%s: expected the %s limit to be exceeded in binary, got %v", test.limits, test.limit, err)
+		}
+		if result, err := loaded.Run(Variables{"x": 1}); err != nil || result != 2 {
+			t.Errorf("%s: expected the program to be unchanged, got %v (%v)", test.limits, result, err)
+		}
+	}
+
+	// a program is left as it was when its variables do not match
+	loaded, err := Compile("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := `{"version": 1, "backend": "bytecode", "policy": "propagate", "variables": ["y"], "tree": {"kind": "variable", "name": "x"}}`
+	if err := loaded.UnmarshalJSON([]byte(invalid)); err == nil || loaded.Expression() != "x + 1" {
+		t.Errorf("expected the program to be unchanged, got %v (%v)", loaded.Expression(), err)
+	}
+}
@@ -0,0 +1,617 @@
+package nparser
+
+import (
+	"cmp"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+)
+
+// maxPolynomialDegree is the largest degree of the polynomials the solver expands
+const maxPolynomialDegree = 64
+
+// Equation is an equation between two expressions
+type Equation struct {
+	Left  *Node
+	Right *Node
+}
+
+// String formats the equation canonically
+func (eq *Equation) String() string {
+	return eq.Left.String() + " = " + eq.Right.String()
+}
+
+// ParseEquation parses an equation made of two expressions separated by =. Operands written
+// next to each other are read as a product, as in 3x + 2 = 11.
+func (np *Nparser) ParseEquation() (*Equation, error) {
+	parser := *np
+	parser.implicitMultiplication = true
+	parser.equation = true
+	tree, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if tree.Kind != OperatorNode || tree.Name != EQUALS || tree.Args[0].hasEquals() || tree.Args[1].hasEquals() {
+		return nil, ErrEquation{Reason: "expected a single ="}
+	}
+	return &Equation{Left: tree.Args[0], Right: tree.Args[1]}, nil
+}
+
+// hasEquals checks if = appears anywhere in the tree
+func (node *Node) hasEquals() bool {
+	if node.Kind == OperatorNode && node.Name == EQUALS {
+		return true
+	}
+	return slices.ContainsFunc(node.Args, (*Node).hasEquals)
+}
+
+// Solution holds the roots of a polynomial equation in a single variable
+type Solution struct {
+	Variable string
+	Degree   int
+	// Roots are repeated as many times as their multiplicity, the real ones first in increasing order
+	Roots []complex128
+	// Exact is true when the roots are given by formulas, up to degree 4, and false when they are approximated
+	Exact bool
+	// Identity is true when the equation holds whatever the value of the variable
+	Identity bool
+}
+
+// RealRoots returns the real roots of the solution
+func (s Solution) RealRoots() []float64 {
+	var roots []float64
+	for _, root := range s.Roots {
+		if imag(root) == 0 {
+			roots = append(roots, real(root))
+		}
+	}
+	return roots
+}
+
+// Solve solves a polynomial equation in a single variable
+func Solve(equation string) (Solution, error) {
+	return New(equation).Solve()
+}
+
+// Solve parses the expression as an equation and solves it
+func (np *Nparser) Solve() (Solution, error) {
+	eq, err := np.ParseEquation()
+	if err != nil {
+		return Solution{}, err
+	}
+	return eq.Solve()
+}
+
+// Solve finds the roots of an equation that is polynomial in its only variable, including the
+// complex ones. They are given by formulas up to degree 4, and approximated beyond.
+func (eq *Equation) Solve() (Solution, error) {
+	names := eq.Right.variables(eq.Left.variables(nil))
+	if len(names) > 1 {
+		return Solution{}, ErrEquation{Reason: "expected a single variable, got " + strings.Join(names, ", ")}
+	}
+	var variable string
+	if len(names) == 1 {
+		variable = names[0]
+	}
+
+	left, err := toPolynomial(eq.Left, variable)
+	if err != nil {
+		return Solution{}, err
+	}
+	right, err := toPolynomial(eq.Right, variable)
+	if err != nil {
+		return Solution{}, err
+	}
+	p := left.add(right, -1).trim()
+
+	solution := Solution{Variable: variable, Degree: max(len(p)-1, 0), Exact: true}
+	switch len(p) {
+	case 0:
+		solution.Identity = true
+		return solution, nil
+	case 1:
+		// a constant that is not zero, without any root
+		return solution, nil
+	}
+	solution.Roots, solution.Exact = p.roots()
+	return solution, nil
+}
+
+// polynomial holds the coefficients of a polynomial, from the constant term up
+type polynomial []float64
+
+// toPolynomial expands a tree into a polynomial in a variable
+func toPolynomial(node *Node, variable string) (polynomial, error) {
+	switch node.Kind {
+	case NumberNode:
+		return polynomial{node.Value}, nil
+	case VariableNode:
+		if node.Name != variable {
+			return nil, ErrNotPolynomial{Variable: variable}
+		}
+		return polynomial{0, 1}, nil
+	}
+
+	args := make([]polynomial, len(node.Args))
+	constant := true
+	for i, arg := range node.Args {
+		var err error
+		if args[i], err = toPolynomial(arg, variable); err != nil {
+			return nil, err
+		}
+		args[i] = args[i].trim()
+		constant = constant && len(args[i]) <= 1
+	}
+
+	var result polynomial
+	switch {
+	case node.Kind == OperatorNode && node.Name == UMINUS:
+		result = args[0].scale(-1)
+	case node.Kind == OperatorNode && node.Name == PLUS:
+		result = args[0].add(args[1], 1)
+	case node.Kind == OperatorNode && node.Name == MINUS:
+		result = args[0].add(args[1], -1)
+	case node.Kind == OperatorNode && node.Name == MUL:
+		result = args[0].mul(args[1])
+	case node.Kind == OperatorNode && node.Name == DIV && len(args[1]) <= 1:
+		if len(args[1]) == 0 {
+			return nil, ErrDivisionByZero{}
+		}
+		result = args[0].scale(1 / args[1][0])
+	case node.Kind == OperatorNode && node.Name == POW && len(args[1]) <= 1 && !constant:
+		exponent := args[1].at(0)
+		if exponent < 0 || exponent != math.Trunc(exponent) {
+			return nil, ErrNotPolynomial{Variable: variable}
+		}
+		if exponent*float64(len(args[0])-1) > maxPolynomialDegree {
+			return nil, ErrLimitExceeded{Limit: LimitDegree, Max: maxPolynomialDegree}
+		}
+		result = polynomial{1}
+		for range int(exponent) {
+			result = result.mul(args[0])
+		}
+	case constant && !node.isVolatile():
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg.at(0)
+		}
+		value, err := applyNode(node, values)
+		if err != nil {
+			return nil, err
+		}
+		result = polynomial{value}
+	default:
+		return nil, ErrNotPolynomial{Variable: variable}
+	}
+
+	if len(result.trim()) > maxPolynomialDegree+1 {
+		return nil, ErrLimitExceeded{Limit: LimitDegree, Max: maxPolynomialDegree}
+	}
+	return result, nil
+}
+
+// at returns the coefficient of a power, zero beyond the degree
+func (p polynomial) at(k int) float64 {
+	if k < len(p) {
+		return p[k]
+	}
+	return 0
+}
+
+// trim drops the zero coefficients of the highest powers
+func (p polynomial) trim() polynomial {
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// scale multiplies a polynomial by a constant
+func (p polynomial) scale(factor float64) polynomial {
+	result := make(polynomial, len(p))
+	for k := range p {
+		result[k] = p[k] * factor
+	}
+	return result
+}
+
+// add adds another polynomial multiplied by a sign
+func (p polynomial) add(other polynomial, sign float64) polynomial {
+	result := make(polynomial, max(len(p), len(other)))
+	for k := range result {
+		result[k] = p.at(k) + sign*other.at(k)
+	}
+	return result
+}
+
+// mul multiplies two polynomials
+func (p polynomial) mul(other polynomial) polynomial {
+	if len(p) == 0 || len(other) == 0 {
+		return nil
+	}
+	result := make(polynomial, len(p)+len(other)-1)
+	for i := range p {
+		for j := range other {
+			result[i+j] += p[i] * other[j]
+		}
+	}
+	return result
+}
+
+// eval evaluates the polynomial and its derivative at a complex value
+func (p polynomial) eval(z complex128) (complex128, complex128) {
+	var value, derivative complex128
+	for k := len(p) - 1; k >= 0; k-- {
+		derivative = derivative*z + value
+		value = value*z + complex(p[k], 0)
+	}
+	return value, derivative
+}
+
+// roots finds the roots of a polynomial of degree 1 or more, by formulas up to degree 4 and
+// by the Durand-Kerner method beyond, and whether they were found by formulas
+func (p polynomial) roots() ([]complex128, bool) {
+	var roots []complex128
+	// the roots at zero are factored out, being exact
+	for p[0] == 0 {
+		roots = append(roots, 0)
+		p = p[1:]
+	}
+
+	exact := true
+	c := make([]complex128, len(p))
+	for k := range p {
+		c[k] = complex(p[k]/p[len(p)-1], 0)
+	}
+	switch len(p) - 1 {
+	case 0:
+	case 1:
+		roots = append(roots, -c[0])
+	case 2:
+		roots = append(roots, quadraticRoots(1, c[1], c[0])...)
+	case 3:
+		roots = append(roots, cubicRoots(c[2], c[1], c[0])...)
+	case 4:
+		roots = append(roots, quarticRoots(c[3], c[2], c[1], c[0])...)
+	default:
+		roots = append(roots, durandKerner(c)...)
+		exact = false
+	}
+
+	for i, root := range roots {
+		if root != 0 {
+			root = p.polish(root)
+		}
+		// adding zero turns negative zeros into zeros
+		roots[i] = complex(real(root)+0, imag(root)+0)
+	}
+	slices.SortFunc(roots, func(a, b complex128) int {
+		// real roots first
+		if (imag(a) == 0) != (imag(b) == 0) {
+			if imag(a) == 0 {
+				return -1
+			}
+			return 1
+		}
+		if real(a) != real(b) {
+			return cmp.Compare(real(a), real(b))
+		}
+		return cmp.Compare(imag(a), imag(b))
+	})
+	return roots, exact
+}
+
+// polish refines a root with a few steps of Newton's method, as long as they improve it, and
+// drops an imaginary part lost in rounding errors
+func (p polynomial) polish(root complex128) complex128 {
+	value, derivative := p.eval(root)
+	for range 4 {
+		if value == 0 || derivative == 0 {
+			break
+		}
+		next := root - value/derivative
+		nextValue, nextDerivative := p.eval(next)
+		if cmplx.Abs(nextValue) >= cmplx.Abs(value) {
+			break
+		}
+		root, value, derivative = next, nextValue, nextDerivative
+	}
+	if math.Abs(imag(root)) <= 1e-9*max(1, cmplx.Abs(root)) {
+		root = complex(real(root), 0)
+	}
+	return root
+}
+
+// quadraticRoots solves a z^2 + b z + c = 0, avoiding the cancellation of the usual formula
+func quadraticRoots(a, b, c complex128) []complex128 {
+	d := cmplx.Sqrt(b*b - 4*a*c)
+	if real(cmplx.Conj(b)*d) < 0 {
+		d = -d
+	}
+	q := -(b + d) / 2
+	if q == 0 {
+		return []complex128{0, 0}
+	}
+	return []complex128{q / a, c / q}
+}
+
+// cubicRoots solves z^3 + b z^2 + c z + d = 0 by Cardano's formula
+func cubicRoots(b, c, d complex128) []complex128 {
+	// z = t - b/3 gives t^3 + p t + q = 0
+	shift := -b / 3
+	p := c - b*b/3
+	q := 2*b*b*b/27 - b*c/3 + d
+	if p == 0 && q == 0 {
+		return []complex128{shift, shift, shift}
+	}
+
+	sqrt := cmplx.Sqrt(q*q/4 + p*p*p/27)
+	cube := -q/2 + sqrt
+	if other := -q/2 - sqrt; cmplx.Abs(other) > cmplx.Abs(cube) {
+		cube = other
+	}
+	u := cmplx.Pow(cube, 1.0/3)
+	// the cube roots of unity
+	omega := complex(-0.5, math.Sqrt(3)/2)
+	roots := make([]complex128, 3)
+	for k := range roots {
+		roots[k] = u - p/(3*u) + shift
+		u *= omega
+	}
+	return roots
+}
+
+// quarticRoots solves z^4 + b z^3 + c z^2 + d z + e = 0 by Ferrari's method
+func quarticRoots(b, c, d, e complex128) []complex128 {
+	// z = y - b/4 gives y^4 + p y^2 + q y + r = 0
+	shift := -b / 4
+	p := c - 3*b*b/8
+	q := d - b*c/2 + b*b*b/8
+	r := e - b*d/4 + b*b*c/16 - 3*b*b*b*b/256
+
+	var roots []complex128
+	if cmplx.Abs(q) <= 1e-14*max(1, cmplx.Abs(p), cmplx.Abs(r)) {
+		// a quadratic in y^2
+		for _, square := range quadraticRoots(1, p, r) {
+			root := cmplx.Sqrt(square)
+			roots = append(roots, root, -root)
+		}
+	} else {
+		// m makes y^4 + p y^2 + q y + r a difference of squares, (y^2 + p/2 + m)^2 - (s y - q/(2s))^2 with s^2 = 2m
+		var m complex128
+		for _, root := range cubicRoots(p, p*p/4-r, -q*q/8) {
+			if cmplx.Abs(root) > cmplx.Abs(m) {
+				m = root
+			}
+		}
+		s := cmplx.Sqrt(2 * m)
+		roots = append(roots, quadraticRoots(1, -s, p/2+m+q/(2*s))...)
+		roots = append(roots, quadraticRoots(1, s, p/2+m-q/(2*s))...)
+	}
+	for i := range roots {
+		roots[i] += shift
+	}
+	return roots
+}
+
+// durandKerner approximates the roots of a monic polynomial, given by its coefficients from the
+// constant term up, by refining guesses for all of them at once
+func durandKerner(c []complex128) []complex128 {
+	n := len(c) - 1
+	eval := func(z complex128) complex128 {
+		var value complex128
+		for k := n; k >= 0; k-- {
+			value = value*z + c[k]
+		}
+		return value
+	}
+
+	// guesses spread around a circle holding every root
+	bound := 0.0
+	for _, coefficient := range c[:n] {
+		bound = max(bound, cmplx.Abs(coefficient))
+	}
+	roots := make([]complex128, n)
+	guess := complex(0.4, 0.9)
+	for k := range roots {
+		roots[k] = complex(1+bound, 0) * cmplx.Pow(guess, complex(float64(k), 0)) / complex(math.Pow(cmplx.Abs(guess), float64(k)), 0)
+	}
+
+	for range 1000 {
+		change := 0.0
+		for k := range roots {
+			denominator := complex(1, 0)
+			for j := range roots {
+				if j != k {
+					denominator *= roots[k] - roots[j]
+				}
+			}
+			if denominator == 0 {
+				continue
+			}
+			step := eval(roots[k]) / denominator
+			roots[k] -= step
+			change = max(change, cmplx.Abs(step)/max(1, cmplx.Abs(roots[k])))
+		}
+		if change < 1e-15 {
+			break
+		}
+	}
+	return roots
+}
+
+// linearForm is a sum of variables multiplied by coefficients, plus a constant
+type linearForm struct {
+	coefficients map[string]float64
+	constant     float64
+}
+
+// SolveSystem solves a system of linear equations, given as text
+func SolveSystem(equations ...string) (map[string]float64, error) {
+	parsed := make([]*Equation, len(equations))
+	for i, equation := range equations {
+		var err error
+		if parsed[i], err = New(equation).ParseEquation(); err != nil {
+			return nil, err
+		}
+	}
+	return SolveLinear(parsed)
+}
+
+// SolveLinear solves a system of linear equations by Gaussian elimination, failing with an
+// ErrNoUniqueSolution if the equations are inconsistent or do not determine every variable
+func SolveLinear(equations []*Equation) (map[string]float64, error) {
+	var names []string
+	forms := make([]linearForm, len(equations))
+	for i, eq := range equations {
+		names = eq.Right.variables(eq.Left.variables(names))
+		left, err := toLinear(eq.Left)
+		if err == nil {
+			var right linearForm
+			right, err = toLinear(eq.Right)
+			forms[i] = left.add(right, -1)
+		}
+		if _, ok := err.(ErrNotLinear); ok {
+			return nil, ErrNotLinear{Equation: eq.String()}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the augmented matrix of the system, with a row per equation and the constants last
+	n := len(names)
+	rows := make([][]float64, len(forms))
+	scale := 0.0
+	for i, form := range forms {
+		rows[i] = make([]float64, n+1)
+		for j, name := range names {
+			rows[i][j] = form.coefficients[name]
+			scale = max(scale, math.Abs(rows[i][j]))
+		}
+		rows[i][n] = -form.constant
+		scale = max(scale, math.Abs(form.constant))
+	}
+	// below the tolerance, values are taken for zeros lost in rounding errors
+	tolerance := 1e-10 * max(scale, 1)
+
+	rank := 0
+	for column := 0; column < n && rank < len(rows); column++ {
+		pivot := rank
+		for i := rank + 1; i < len(rows); i++ {
+			if math.Abs(rows[i][column]) > math.Abs(rows[pivot][column]) {
+				pivot = i
+			}
+		}
+		if math.Abs(rows[pivot][column]) <= tolerance {
+			continue
+		}
+		rows[rank], rows[pivot] = rows[pivot], rows[rank]
+		for i := range rows {
+			if i == rank {
+				continue
+			}
+			factor := rows[i][column] / rows[rank][column]
+			for j := column; j <= n; j++ {
+				rows[i][j] -= factor * rows[rank][j]
+			}
+		}
+		rank++
+	}
+
+	for _, row := range rows[rank:] {
+		if math.Abs(row[n]) > tolerance {
+			return nil, ErrNoUniqueSolution{Reason: "the equations are inconsistent"}
+		}
+	}
+	if rank < n {
+		return nil, ErrNoUniqueSolution{Reason: "the equations do not determine every variable"}
+	}
+
+	solution := make(map[string]float64, n)
+	for i, name := range names {
+		solution[name] = rows[i][n] / rows[i][i]
+	}
+	return solution, nil
+}
+
+// toLinear expands a tree into a linear form
+func toLinear(node *Node) (linearForm, error) {
+	switch node.Kind {
+	case NumberNode:
+		return linearForm{constant: node.Value}, nil
+	case VariableNode:
+		return linearForm{coefficients: map[string]float64{node.Name: 1}}, nil
+	}
+
+	args := make([]linearForm, len(node.Args))
+	constant := true
+	for i, arg := range node.Args {
+		var err error
+		if args[i], err = toLinear(arg); err != nil {
+			return linearForm{}, err
+		}
+		constant = constant && args[i].isConstant()
+	}
+
+	switch {
+	case node.Kind == OperatorNode && node.Name == UMINUS:
+		return args[0].scale(-1), nil
+	case node.Kind == OperatorNode && node.Name == PLUS:
+		return args[0].add(args[1], 1), nil
+	case node.Kind == OperatorNode && node.Name == MINUS:
+		return args[0].add(args[1], -1), nil
+	case node.Kind == OperatorNode && node.Name == MUL && args[0].isConstant():
+		return args[1].scale(args[0].constant), nil
+	case node.Kind == OperatorNode && node.Name == MUL && args[1].isConstant():
+		return args[0].scale(args[1].constant), nil
+	case node.Kind == OperatorNode && node.Name == DIV && args[1].isConstant():
+		if args[1].constant == 0 {
+			return linearForm{}, ErrDivisionByZero{}
+		}
+		return args[0].scale(1 / args[1].constant), nil
+	case node.Kind == OperatorNode && node.Name == POW && args[1].isConstant() && args[1].constant == 1:
+		return args[0], nil
+	case constant && !node.isVolatile():
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg.constant
+		}
+		value, err := applyNode(node, values)
+		if err != nil {
+			return linearForm{}, err
+		}
+		return linearForm{constant: value}, nil
+	}
+	return linearForm{}, ErrNotLinear{}
+}
+
+// isConstant checks if a linear form does not depend on any variable
+func (f linearForm) isConstant() bool {
+	for _, coefficient := range f.coefficients {
+		if coefficient != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scale multiplies a linear form by a constant
+func (f linearForm) scale(factor float64) linearForm {
+	result := linearForm{coefficients: make(map[string]float64, len(f.coefficients)), constant: f.constant * factor}
+	for name, coefficient := range f.coefficients {
+		result.coefficients[name] = coefficient * factor
+	}
+	return result
+}
+
+// add adds another linear form multiplied by a sign
+func (f linearForm) add(other linearForm, sign float64) linearForm {
+	result := f.scale(1)
+	result.constant += sign * other.constant
+	for name, coefficient := range other.coefficients {
+		result.coefficients[name] += sign * coefficient
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package nparser
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		equation string
+		degree   int
+		exact    bool
+		roots    []complex128
+	}{
+		{"3x + 2 = 11", 1, true, []complex128{3}},
+		{"x^2 - 5x + 6 = 0", 2, true, []complex128{2, 3}},
+		{"x^2 + 1 = 0", 2, true, []complex128{complex(0, -1), complex(0, 1)}},
+		{"(x - 1)^2 = 0", 2, true, []complex128{1, 1}},
+		{"x^3 = 6x^2 - 11x + 6", 3, true, []complex128{1, 2, 3}},
+		{"x^3 - 1 = 0", 3, true, []complex128{1, complex(-0.5, -math.Sqrt(3)/2), complex(-0.5, math.Sqrt(3)/2)}},
+		{"(x - 2)^3 = 0", 3, true, []complex128{2, 2, 2}},
+		{"x^3 + x = 0", 3, true, []complex128{0, complex(0, -1), complex(0, 1)}},
+		{"(x - 1)(x - 2)(x - 3)(x - 4) = 0", 4, true, []complex128{1, 2, 3, 4}},
+		{"x^4 - 5x^2 + 4 = 0", 4, true, []complex128{-2, -1, 1, 2}},
+		{"x^4 + 1 = 0", 4, true, []complex128{
+			complex(-math.Sqrt2/2, -math.Sqrt2/2), complex(-math.Sqrt2/2, math.Sqrt2/2),
+			complex(math.Sqrt2/2, -math.Sqrt2/2), complex(math.Sqrt2/2, math.Sqrt2/2),
+		}},
+		{"x^4 + 2x^3 - 13x^2 - 14x + 24 = 0", 4, true, []complex128{-4, -2, 1, 3}},
+		{"(t - 1)(t - 2)(t - 3)(t - 4)(t - 5) = 0", 5, false, []complex128{1, 2, 3, 4, 5}},
+		{"y^6 = 1", 6, false, []complex128{
+			-1, 1, complex(-0.5, -math.Sqrt(3)/2), complex(-0.5, math.Sqrt(3)/2),
+			complex(0.5, -math.Sqrt(3)/2), complex(0.5, math.Sqrt(3)/2),
+		}},
+		{"2 (x + 1) / 4 = sqrt(16)", 1, true, []complex128{7}},
+	}
+
+	for _, test := range tests {
+		solution, err := Solve(test.equation)
+		if err != nil {
+			t.Fatalf("%s: %v", test.equation, err)
+		}
+		if solution.Degree != test.degree || solution.Exact != test.exact || len(solution.Roots) != len(test.roots) {
+			t.Errorf("%s: expected %d roots of degree %d (exact %v), got %+v", test.equation, len(test.roots), test.degree, test.exact, solution)
+			continue
+		}
+		for i, root := range test.roots {
+			if cmplx.Abs(solution.Roots[i]-root) > 1e-6 {
+				t.Errorf("%s: expected the roots %v, got %v", test.equation, test.roots, solution.Roots)
+				break
+			}
+		}
+	}
+}
+
+func TestSolveSpecialCases(t *testing.T) {
+	solution, err := Solve("2(x + 1) = 2x + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !solution.Identity || len(solution.Roots) != 0 {
+		t.Errorf("expected an identity, got %+v", solution)
+	}
+
+	solution, err = Solve("x + 1 = x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution.Identity || len(solution.Roots) != 0 {
+		t.Errorf("expected no root, got %+v", solution)
+	}
+
+	solution, err = Solve("x^2 + 1 = 2x^2 - 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := solution.RealRoots(); len(roots) != 2 || roots[0] != -2 || roots[1] != 2 {
+		t.Errorf("expected the real roots -2 and 2, got %v", roots)
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	var equationErr ErrEquation
+	for _, equation := range []string{"x + 1", "x = 1 = 2", "x + y = 2", "(x = 1) = 1", "sin(x = 1) = 0"} {
+		if _, err := Solve(equation); !errors.As(err, &equationErr) {
+			t.Errorf("%s: expected ErrEquation, got %v", equation, err)
+		}
+	}
+	for _, equation := range []string{"sin(x) = 0", "1 / x = 2", "x ^ 0.5 = 2", "x ^ -1 = 2"} {
+		if _, err := Solve(equation); !errors.Is(err, ErrNotPolynomial{Variable: "x"}) {
+			t.Errorf("%s: expected ErrNotPolynomial, got %v", equation, err)
+		}
+	}
+	degreeErr := ErrLimitExceeded{Limit: LimitDegree, Max: maxPolynomialDegree}
+	for _, equation := range []string{"x ^ 100 = 1", "x ^ 1000000 = 1", "(x ^ 64) ^ 64 = 1", "x ^ 40 * (x + 1) ^ 40 = 0"} {
+		if _, err := Solve(equation); !errors.Is(err, degreeErr) {
+			t.Errorf("%s: expected %v, got %v", equation, degreeErr, err)
+		}
+	}
+	if _, err := New("x = 1").Parse(); !errors.As(err, new(ErrUnexpectedChar)) {
+		t.Errorf("expected = to be rejected outside equations, got %v", err)
+	}
+	if _, err := Solve("x / 0 = 1"); !errors.Is(err, ErrDivisionByZero{}) {
+		t.Errorf("expected ErrDivisionByZero, got %v", err)
+	}
+}
+
+func TestSolveSystem(t *testing.T) {
+	solution, err := SolveSystem("x + y = 3", "x - y = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution["x"] != 2 || solution["y"] != 1 {
+		t.Errorf("expected x = 2 and y = 1, got %v", solution)
+	}
+
+	solution, err = SolveSystem("2a + 3b - c = 4", "a - b + 2c = 7", "3a + b + c = 10", "(a + b + c) / 2 = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]float64{"a": 2, "b": 1, "c": 3} {
+		if math.Abs(solution[name]-expected) > 1e-9 {
+			t.Errorf("expected %s = %v, got %v", name, expected, solution[name])
+		}
+	}
+
+	tests := []struct {
+		equations []string
+		expected  error
+	}{
+		{[]string{"x + y = 3", "2x + 2y = 6"}, ErrNoUniqueSolution{Reason: "the equations do not determine every variable"}},
+		{[]string{"x + y = 3", "x + y = 4"}, ErrNoUniqueSolution{Reason: "the equations are inconsistent"}},
+		{[]string{"x = 1", "x = 2", "y = 0"}, ErrNoUniqueSolution{Reason: "the equations are inconsistent"}},
+		{[]string{"x * y = 3", "x = 1"}, ErrNotLinear{Equation: "x * y = 3"}},
+		{[]string{"x / 0 = 1"}, ErrDivisionByZero{}},
+	}
+	for _, test := range tests {
+		if _, err := SolveSystem(test.equations...); !errors.Is(err, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.equations, test.expected, err)
+		}
+	}
+}
+
+func TestImplicitMultiplication(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"3x + 2", "3 * x + 2"},
+		{"2(x + 1)", "2 * (x + 1)"},
+		{"(x + 1)(x - 1)", "(x + 1) * (x - 1)"},
+		{"2sin(x)cos(x)", "2 * sin(x) * cos(x)"},
+		{"x²y", "x ^ 2 * y"},
+		{"2√x", "2 * sqrt(x)"},
+		{"rate months", "rate * months"},
+		{"x - 1", "x - 1"},
+	}
+
+	for _, test := range tests {
+		np := New(test.expression)
+		np.SetImplicitMultiplication(true)
+		tree, err := np.Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if tree.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.expression, test.expected, tree)
+		}
+	}
+
+	if _, err := New("3x").Parse(); err == nil {
+		t.Errorf("expected implicit multiplication to be disabled by default")
+	}
+}
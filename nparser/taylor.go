@@ -0,0 +1,432 @@
+package nparser
+
+import "math"
+
+// Series is a Taylor polynomial approximating a function around a point
+type Series struct {
+	Variable string
+	At       float64
+	// Coefficients are the coefficients of the powers of (variable - at), from the constant term up
+	Coefficients []float64
+}
+
+// Eval evaluates the polynomial at a value of its variable
+func (s Series) Eval(x float64) float64 {
+	result := 0.0
+	for k := len(s.Coefficients) - 1; k >= 0; k-- {
+		result = result*(x-s.At) + s.Coefficients[k]
+	}
+	return result
+}
+
+// String formats the polynomial as an expression, leaving out the terms with a zero coefficient
+// and writing the coefficients that are not finite as the divisions by zero giving them
+func (s Series) String() string {
+	offset := &Node{Kind: VariableNode, Name: s.Variable}
+	switch {
+	case s.At > 0:
+		offset = operatorNode(MINUS, offset, &Node{Kind: NumberNode, Value: s.At})
+	case s.At < 0:
+		offset = operatorNode(PLUS, offset, &Node{Kind: NumberNode, Value: -s.At})
+	}
+
+	var polynomial *Node
+	for k, coefficient := range s.Coefficients {
+		if coefficient == 0 {
+			continue
+		}
+		var term *Node
+		switch k {
+		case 0:
+			term = constantNode(math.Abs(coefficient))
+		case 1:
+			term = offset
+		default:
+			term = operatorNode(POW, offset, &Node{Kind: NumberNode, Value: float64(k)})
+		}
+		if k > 0 && math.Abs(coefficient) != 1 {
+			term = operatorNode(MUL, constantNode(math.Abs(coefficient)), term)
+		}
+
+		switch {
+		case polynomial == nil && coefficient < 0:
+			polynomial = operatorNode(UMINUS, term)
+		case polynomial == nil:
+			polynomial = term
+		case coefficient < 0:
+			polynomial = operatorNode(MINUS, polynomial, term)
+		default:
+			polynomial = operatorNode(PLUS, polynomial, term)
+		}
+	}
+	if polynomial == nil {
+		return "0"
+	}
+	return polynomial.String()
+}
+
+// Taylor computes the Taylor polynomial of an expression of a single variable around a point, up to an order
+func Taylor(expression, variable string, at float64, order int) (Series, error) {
+	p, err := Compile(expression)
+	if err != nil {
+		return Series{}, err
+	}
+	return p.Taylor(variable, at, order, nil)
+}
+
+// Nderiv computes the derivative of an expression of a single variable at a point
+func Nderiv(expression, variable string, at float64) (float64, error) {
+	p, err := Compile(expression)
+	if err != nil {
+		return 0, err
+	}
+	return p.Nderiv(variable, at, nil)
+}
+
+// Nderiv computes the derivative of the program with respect to a variable at a point, with the other variables fixed
+func (p *Program) Nderiv(variable string, at float64, fixed Variables) (float64, error) {
+	s, err := p.Taylor(variable, at, 1, fixed)
+	if err != nil {
+		return 0, err
+	}
+	return s.Coefficients[1], nil
+}
+
+// Taylor computes the Taylor polynomial of the program in a variable around a point, up to an order,
+// with the other variables fixed. The coefficients are computed exactly by propagating truncated
+// series through the operators and the elementary functions; those of the other built-in functions
+// are approximated by finite differences, which lose accuracy as the order grows. Random functions
+// and integer operators cannot be differentiated. Every coefficient counts as an evaluation against
+// the limits of the program, and its policy applies to the value of the function: a substituted
+// value has a constant series.
+func (p *Program) Taylor(variable string, at float64, order int, fixed Variables) (Series, error) {
+	if order < 0 {
+		return Series{}, ErrTaylorOrder{Order: order}
+	}
+	if err := p.limits.check(LimitSteps, len(p.code)*(order+1), p.limits.MaxSteps); err != nil {
+		return Series{}, err
+	}
+	e := &jetEvaluator{order: order, variable: variable, at: at, fixed: fixed, limits: p.limits, checked: p.policy.policy == ErrorPolicy}
+	coefficients, err := e.eval(fold(p.root))
+	if err != nil {
+		return Series{}, err
+	}
+	if value := p.policy.apply(coefficients[0]); value != coefficients[0] && !math.IsNaN(value) {
+		coefficients = e.constant(value)
+	}
+	return Series{Variable: variable, At: at, Coefficients: coefficients}, nil
+}
+
+// jet holds the Taylor coefficients of a function of the variable being expanded, truncated at an order
+type jet []float64
+
+// jetEvaluator evaluates a tree over jets
+type jetEvaluator struct {
+	order    int
+	variable string
+	at       float64
+	fixed    Variables
+	limits   Limits
+	// checked fails the evaluation on values that are not finite, under ErrorPolicy
+	checked bool
+}
+
+// constant returns the jet of a constant
+func (e *jetEvaluator) constant(value float64) jet {
+	j := make(jet, e.order+1)
+	j[0] = value
+	return j
+}
+
+// eval evaluates a tree over jets
+func (e *jetEvaluator) eval(node *Node) (jet, error) {
+	switch node.Kind {
+	case NumberNode:
+		return e.constant(node.Value), nil
+	case VariableNode:
+		if node.Name == e.variable {
+			j := e.constant(e.at)
+			if e.order > 0 {
+				j[1] = 1
+			}
+			return j, nil
+		}
+		value, ok := e.fixed[node.Name]
+		if !ok {
+			return nil, ErrUndefinedVariable{Variable: node.Name}
+		}
+		return e.constant(value), nil
+	}
+
+	args := make([]jet, len(node.Args))
+	for i, arg := range node.Args {
+		var err error
+		if args[i], err = e.eval(arg); err != nil {
+			return nil, err
+		}
+	}
+	result, err := e.apply(node, args)
+	if err != nil || !e.checked {
+		return result, err
+	}
+	// under ErrorPolicy, every operator and function checks its value
+	divisor := math.NaN()
+	if node.Kind == OperatorNode && node.Name == DIV {
+		divisor = args[1][0]
+	}
+	if err := checkFinite(node.Name, result[0], divisor); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// apply applies the operator or function of a node to the jets of its arguments
+func (e *jetEvaluator) apply(node *Node, args []jet) (jet, error) {
+	if node.Kind == OperatorNode {
+		switch node.Name {
+		case UMINUS:
+			return args[0].scale(-1), nil
+		case PLUS:
+			return args[0].add(args[1], 1), nil
+		case MINUS:
+			return args[0].add(args[1], -1), nil
+		case MUL:
+			return args[0].mul(args[1]), nil
+		case DIV:
+			return args[0].div(args[1]), nil
+		case POW:
+			if err := e.limits.checkExponent(args[1][0]); err != nil {
+				return nil, err
+			}
+			return args[0].pow(args[1]), nil
+		}
+		return nil, ErrNotDifferentiable{Function: node.Name}
+	}
+
+	switch node.Name {
+	case "sin":
+		sin, _ := args[0].sinCos()
+		return sin, nil
+	case "cos":
+		_, cos := args[0].sinCos()
+		return cos, nil
+	case "tan":
+		sin, cos := args[0].sinCos()
+		return sin.div(cos), nil
+	case "cosec":
+		sin, _ := args[0].sinCos()
+		return e.constant(1).div(sin), nil
+	case "sec":
+		_, cos := args[0].sinCos()
+		return e.constant(1).div(cos), nil
+	case "cot":
+		sin, cos := args[0].sinCos()
+		return cos.div(sin), nil
+	case "log":
+		return args[0].log(), nil
+	case "log10":
+		return args[0].log().scale(1 / math.Ln10), nil
+	case "log2":
+		return args[0].log().scale(1 / math.Ln2), nil
+	case "sqrt":
+		return args[0].powConstant(0.5), nil
+	case "max", "min":
+		// the series of the selected argument, which holds on either side of the point unless it is a tie
+		selected := args[0]
+		for _, arg := range args[1:] {
+			if node.Name == "max" && arg[0] > selected[0] || node.Name == "min" && arg[0] < selected[0] {
+				selected = arg
+			}
+		}
+		return selected, nil
+	}
+
+	desc, ok := functionList[node.Name]
+	if !ok {
+		return nil, ErrUnknownFunction{Function: node.Name}
+	}
+	if desc.fn == nil {
+		return nil, ErrNotDifferentiable{Function: node.Name}
+	}
+	return e.differences(desc, args), nil
+}
+
+// differences approximates the jet of a function applied to jets by central finite differences
+// of the function along the series of its arguments
+func (e *jetEvaluator) differences(desc FunctionDesc, args []jet) jet {
+	defaults := desc.defaults[min(len(args)-desc.arity, len(desc.defaults)):]
+	values := make([]float64, len(args)+len(defaults))
+	copy(values[len(args):], defaults)
+	// g evaluates the function at an offset of the variable, through the series of the arguments
+	g := func(t float64) float64 {
+		for i, arg := range args {
+			values[i] = arg.value(t)
+		}
+		return desc.fn(values...)
+	}
+
+	result := e.constant(g(0))
+	scale := max(1, math.Abs(e.at))
+	for k := 1; k <= e.order; k++ {
+		// the step balancing the truncation and rounding errors of a difference of order k
+		h := math.Pow(epsilon, 1/float64(k+2)) * scale
+		sum, binomial := 0.0, 1.0
+		for i := 0; i <= k; i++ {
+			sign := 1.0
+			if i%2 == 1 {
+				sign = -1
+			}
+			sum += sign * binomial * g((float64(k)/2-float64(i))*h)
+			binomial = binomial * float64(k-i) / float64(i+1)
+		}
+		// the derivative of order k, divided by k! for its coefficient
+		coefficient := sum / math.Pow(h, float64(k))
+		for i := 2; i <= k; i++ {
+			coefficient /= float64(i)
+		}
+		result[k] = coefficient
+	}
+	return result
+}
+
+// epsilon is the difference between 1 and the next representable float64
+const epsilon = 0x1p-52
+
+// value evaluates the truncated series at an offset of the variable
+func (j jet) value(t float64) float64 {
+	result := 0.0
+	for k := len(j) - 1; k >= 0; k-- {
+		result = result*t + j[k]
+	}
+	return result
+}
+
+// scale multiplies a jet by a constant
+func (j jet) scale(factor float64) jet {
+	result := make(jet, len(j))
+	for k := range j {
+		result[k] = j[k] * factor
+	}
+	return result
+}
+
+// add adds another jet multiplied by a sign
+func (j jet) add(other jet, sign float64) jet {
+	result := make(jet, len(j))
+	for k := range j {
+		result[k] = j[k] + sign*other[k]
+	}
+	return result
+}
+
+// mul multiplies two jets
+func (j jet) mul(other jet) jet {
+	result := make(jet, len(j))
+	for k := range result {
+		for i := 0; i <= k; i++ {
+			result[k] += j[i] * other[k-i]
+		}
+	}
+	return result
+}
+
+// div divides two jets
+func (j jet) div(other jet) jet {
+	result := make(jet, len(j))
+	for k := range result {
+		sum := j[k]
+		for i := 1; i <= k; i++ {
+			sum -= other[i] * result[k-i]
+		}
+		result[k] = sum / other[0]
+	}
+	return result
+}
+
+// exp applies the exponential to a jet
+func (j jet) exp() jet {
+	result := make(jet, len(j))
+	result[0] = math.Exp(j[0])
+	for k := 1; k < len(j); k++ {
+		for i := 1; i <= k; i++ {
+			result[k] += float64(i) * j[i] * result[k-i]
+		}
+		result[k] /= float64(k)
+	}
+	return result
+}
+
+// log applies the natural logarithm to a jet
+func (j jet) log() jet {
+	result := make(jet, len(j))
+	result[0] = math.Log(j[0])
+	for k := 1; k < len(j); k++ {
+		sum := j[k]
+		for i := 1; i < k; i++ {
+			sum -= float64(i) * result[i] * j[k-i] / float64(k)
+		}
+		result[k] = sum / j[0]
+	}
+	return result
+}
+
+// sinCos applies the sine and the cosine to a jet
+func (j jet) sinCos() (jet, jet) {
+	sin, cos := make(jet, len(j)), make(jet, len(j))
+	sin[0], cos[0] = math.Sincos(j[0])
+	for k := 1; k < len(j); k++ {
+		for i := 1; i <= k; i++ {
+			sin[k] += float64(i) * j[i] * cos[k-i]
+			cos[k] -= float64(i) * j[i] * sin[k-i]
+		}
+		sin[k] /= float64(k)
+		cos[k] /= float64(k)
+	}
+	return sin, cos
+}
+
+// pow raises a jet to the power of another
+func (j jet) pow(exponent jet) jet {
+	for _, coefficient := range exponent[1:] {
+		if coefficient != 0 {
+			return exponent.mul(j.log()).exp()
+		}
+	}
+	return j.powConstant(exponent[0])
+}
+
+// maxIntegerPower is the largest integer exponent a jet is raised to by repeated multiplication
+const maxIntegerPower = 1 << 16
+
+// powConstant raises a jet to a constant power
+func (j jet) powConstant(exponent float64) jet {
+	if exponent >= 0 && exponent == math.Trunc(exponent) && exponent <= maxIntegerPower {
+		// exact, even where the base is zero or negative
+		result := make(jet, len(j))
+		result[0] = 1
+		base := j
+		for n := int(exponent); n > 0; n >>= 1 {
+			if n&1 == 1 {
+				result = result.mul(base)
+			}
+			base = base.mul(base)
+		}
+		return result
+	}
+
+	result := make(jet, len(j))
+	result[0] = math.Pow(j[0], exponent)
+	for k := 1; k < len(j); k++ {
+		if j[0] == 0 {
+			// the derivatives of a non-integer power are not finite where the base is zero
+			result[k] = math.NaN()
+			continue
+		}
+		sum := 0.0
+		for i := 1; i <= k; i++ {
+			sum += ((exponent+1)*float64(i) - float64(k)) * j[i] * result[k-i]
+		}
+		result[k] = sum / (float64(k) * j[0])
+	}
+	return result
+}
@@ -0,0 +1,220 @@
+package nparser
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/viveknathani/numero/nfinance"
+)
+
+func TestTaylor(t *testing.T) {
+	tests := []struct {
+		expression string
+		at         float64
+		expected   []float64
+	}{
+		{"sin(x)", 0, []float64{0, 1, 0, -1.0 / 6, 0, 1.0 / 120}},
+		{"cos(2 * x)", 0, []float64{1, 0, -2, 0, 2.0 / 3}},
+		{"tan(x)", 0, []float64{0, 1, 0, 1.0 / 3}},
+		{"log(x)", 1, []float64{0, 1, -1.0 / 2, 1.0 / 3, -1.0 / 4}},
+		{"log10(x)", 1, []float64{0, 1 / math.Ln10, -0.5 / math.Ln10}},
+		{"sqrt(x)", 4, []float64{2, 1.0 / 4, -1.0 / 64}},
+		{"(x + 1) ^ 3", 0, []float64{1, 3, 3, 1, 0}},
+		{"(x - 2) ^ 2", 2, []float64{0, 0, 1}},
+		{"1 / (1 - x)", 0, []float64{1, 1, 1, 1, 1}},
+		{"x ^ x", 1, []float64{1, 1, 1}},
+		{"2 ^ x", 0, []float64{1, math.Ln2, math.Ln2 * math.Ln2 / 2}},
+		{"max(x, 1 - x) * 3", 2, []float64{6, 3, 0}},
+		{"sec(x) - cosec(x) + cot(x)", 1, nil},
+	}
+
+	for _, test := range tests {
+		order := max(len(test.expected)-1, 3)
+		series, err := Taylor(test.expression, "x", test.at, order)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if test.expected == nil {
+			// checked against finite differences of the expression itself
+			program, _ := Compile(test.expression)
+			h := 1e-5
+			f := func(x float64) float64 {
+				value, _ := program.Run(Variables{"x": x})
+				return value
+			}
+			test.expected = []float64{f(test.at), (f(test.at+h) - f(test.at-h)) / (2 * h)}
+		}
+		for k, expected := range test.expected {
+			if math.Abs(series.Coefficients[k]-expected) > 1e-6*max(1, math.Abs(expected)) {
+				t.Errorf("%s: expected the coefficient %d to be %v, got %v", test.expression, k, expected, series.Coefficients[k])
+			}
+		}
+	}
+}
+
+func TestTaylorFiniteDifferences(t *testing.T) {
+	program, err := Compile("pmt(rate, 12, 1000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derivative, err := program.Nderiv("rate", 0.05, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := 1e-6
+	expected := (nfinance.Pmt(0.05+h, 12, 1000, 0, 0) - nfinance.Pmt(0.05-h, 12, 1000, 0, 0)) / (2 * h)
+	if math.Abs(derivative-expected) > 1e-4*math.Abs(expected) {
+		t.Errorf("expected %v, got %v", expected, derivative)
+	}
+
+	// through the chain rule
+	series, err := program.Taylor("rate", 0, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsNaN(series.Coefficients[2]) {
+		t.Errorf("expected a second coefficient, got %v", series.Coefficients)
+	}
+}
+
+func TestNderiv(t *testing.T) {
+	derivative, err := Nderiv("x ^ 3 - 2 * x", "x", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derivative != 10 {
+		t.Errorf("expected 10, got %v", derivative)
+	}
+
+	program, err := Compile("a * sin(x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derivative, err = program.Nderiv("x", 0, Variables{"a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derivative != 3 {
+		t.Errorf("expected 3, got %v", derivative)
+	}
+}
+
+func TestTaylorErrors(t *testing.T) {
+	if _, err := Taylor("x + y", "x", 0, 2); !errors.Is(err, ErrUndefinedVariable{Variable: "y"}) {
+		t.Errorf("expected ErrUndefinedVariable, got %v", err)
+	}
+	if _, err := Taylor("x * rand()", "x", 0, 2); !errors.Is(err, ErrNotDifferentiable{Function: "rand"}) {
+		t.Errorf("expected ErrNotDifferentiable, got %v", err)
+	}
+	if _, err := Taylor("x", "x", 0, -1); !errors.Is(err, ErrTaylorOrder{Order: -1}) {
+		t.Errorf("expected ErrTaylorOrder, got %v", err)
+	}
+
+	np := New("x & 3")
+	np.SetIntegerMode(true)
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.Nderiv("x", 1, nil); !errors.Is(err, ErrNotDifferentiable{Function: AND}) {
+		t.Errorf("expected ErrNotDifferentiable, got %v", err)
+	}
+}
+
+func TestTaylorLimitsAndPolicy(t *testing.T) {
+	np := New("x ^ n")
+	np.SetLimits(Limits{MaxExponent: 100})
+	program, err := np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ErrLimitExceeded{Limit: LimitExponent, Max: 100}
+	if _, err := program.Taylor("x", 1, 3, Variables{"n": 1 << 16}); !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+
+	np = New("sin(x) + x")
+	np.SetLimits(Limits{MaxSteps: 12})
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.Taylor("x", 0, 2, nil); err != nil {
+		t.Errorf("expected the expansion to order 2 within the limits, got %v", err)
+	}
+	expected = ErrLimitExceeded{Limit: LimitSteps, Max: 12}
+	if _, err := program.Taylor("x", 0, 3, nil); !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+
+	np = New("1 / x + log(x)")
+	np.SetPolicy(ErrorPolicy, 0)
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.Taylor("x", 0, 2, nil); !errors.Is(err, ErrDivisionByZero{}) {
+		t.Errorf("expected ErrDivisionByZero, got %v", err)
+	}
+	if _, err := program.Taylor("x", -1, 2, nil); !errors.Is(err, ErrDomain{Function: "log"}) {
+		t.Errorf("expected ErrDomain, got %v", err)
+	}
+
+	np = New("log(x)")
+	np.SetPolicy(SubstitutePolicy, 7)
+	program, err = np.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := program.Taylor("x", -1, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(series.Coefficients, []float64{7, 0, 0}) {
+		t.Errorf("expected the substitute as a constant series, got %v", series.Coefficients)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	for _, expression := range []string{"log(x)", "sqrt(x)"} {
+		series, err := Taylor(expression, "x", 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compile(series.String()); err != nil {
+			t.Errorf("%s: expected %q to parse, got %v", expression, series, err)
+		}
+	}
+
+	tests := []struct {
+		series   Series
+		expected string
+	}{
+		{Series{Variable: "x", At: 0, Coefficients: []float64{0, 1, 0, -0.5}}, "x - 0.5 * x ^ 3"},
+		{Series{Variable: "x", At: 2, Coefficients: []float64{1, -3, 0.5}}, "1 - 3 * (x - 2) + 0.5 * (x - 2) ^ 2"},
+		{Series{Variable: "t", At: -1, Coefficients: []float64{-1, 1}}, "-1 + (t + 1)"},
+		{Series{Variable: "x", At: 0, Coefficients: []float64{0, 0}}, "0"},
+		// the series of log(x) and sqrt(x) at 0
+		{Series{Variable: "x", At: 0, Coefficients: []float64{math.Inf(-1), math.Inf(1), math.Inf(-1)}}, "-(1 / 0) + 1 / 0 * x - 1 / 0 * x ^ 2"},
+		{Series{Variable: "x", At: 0, Coefficients: []float64{0, math.NaN(), math.NaN()}}, "0 / 0 * x + 0 / 0 * x ^ 2"},
+	}
+
+	for _, test := range tests {
+		if result := test.series.String(); result != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, result)
+		}
+		program, err := Compile(test.series.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range []float64{-1.5, 0, 0.5, 3} {
+			value, _ := program.Run(Variables{test.series.Variable: x})
+			expected := test.series.Eval(x)
+			if math.IsNaN(value) != math.IsNaN(expected) || math.Abs(value-expected) > 1e-12 {
+				t.Errorf("%s: expected %v at %v, got %v", test.expected, expected, x, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package nplot
+
+import (
+	"bytes"
+	"html"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/viveknathani/numero/nparser"
+)
+
+// margin is the space around the plotting area, in pixels, holding the labels of the axes
+const margin = 48
+
+// Options configures a chart
+type Options struct {
+	// Width and Height are the size of the chart in pixels, 640 by 400 if zero
+	Width  int
+	Height int
+	Title  string
+}
+
+// View is the range of values shown by a chart
+type View struct {
+	MinX, MaxX float64
+	MinY, MaxY float64
+}
+
+// NewView returns the range of values to show for sampled points. The range of y leaves out
+// the extreme values, so that a function going to infinity does not flatten the rest of it.
+func NewView(points []nparser.Point) View {
+	view := View{MinX: math.Inf(1), MaxX: math.Inf(-1)}
+	var ys []float64
+	for _, point := range points {
+		view.MinX = min(view.MinX, point.X)
+		view.MaxX = max(view.MaxX, point.X)
+		if isFinite(point.Y) {
+			ys = append(ys, point.Y)
+		}
+	}
+	if len(points) == 0 {
+		view.MinX, view.MaxX = 0, 1
+	}
+	if view.MinX == view.MaxX {
+		view.MinX, view.MaxX = view.MinX-1, view.MaxX+1
+	}
+	if len(ys) == 0 {
+		view.MinY, view.MaxY = -1, 1
+		return view
+	}
+
+	slices.Sort(ys)
+	// the 5th and 95th percentiles, once there are enough points to tell the extremes
+	skip := 0
+	if len(ys) >= 20 {
+		skip = len(ys) / 20
+	}
+	view.MinY, view.MaxY = ys[skip], ys[len(ys)-1-skip]
+	if view.MinY == view.MaxY {
+		view.MinY, view.MaxY = view.MinY-1, view.MaxY+1
+	}
+	padding := (view.MaxY - view.MinY) / 20
+	view.MinY -= padding
+	view.MaxY += padding
+	return view
+}
+
+// Segments splits sampled points into the runs to draw as lines, breaking at the points
+// without a finite value and at the discontinuities, where the function jumps from above
+// the view to below it or the other way round
+func (view View) Segments(points []nparser.Point) [][]nparser.Point {
+	var segments [][]nparser.Point
+	var segment []nparser.Point
+	for i, point := range points {
+		if !isFinite(point.Y) {
+			if len(segment) > 0 {
+				segments = append(segments, segment)
+			}
+			segment = nil
+			continue
+		}
+		if len(segment) > 0 && view.jumps(points[i-1].Y, point.Y) {
+			segments = append(segments, segment)
+			segment = nil
+		}
+		segment = append(segment, point)
+	}
+	if len(segment) > 0 {
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// jumps checks if a function jumps across the view between two consecutive values
+func (view View) jumps(a, b float64) bool {
+	return a > view.MaxY && b < view.MinY || a < view.MinY && b > view.MaxY
+}
+
+// SVG renders sampled points as a line chart
+func SVG(points []nparser.Point, opts Options) []byte {
+	width, height := opts.Width, opts.Height
+	if width <= 0 {
+		width = 640
+	}
+	if height <= 0 {
+		height = 400
+	}
+	view := NewView(points)
+	plotWidth := float64(max(width-2*margin, 1))
+	plotHeight := float64(max(height-2*margin, 1))
+	toX := func(x float64) float64 {
+		return margin + (x-view.MinX)/(view.MaxX-view.MinX)*plotWidth
+	}
+	toY := func(y float64) float64 {
+		return margin + (view.MaxY-y)/(view.MaxY-view.MinY)*plotHeight
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="` + strconv.Itoa(width) + `" height="` + strconv.Itoa(height) +
+		`" viewBox="0 0 ` + strconv.Itoa(width) + " " + strconv.Itoa(height) + `" font-family="sans-serif" font-size="12">`)
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
+	b.WriteString(`<clipPath id="plot"><rect x="` + coordinate(margin) + `" y="` + coordinate(margin) +
+		`" width="` + coordinate(plotWidth) + `" height="` + coordinate(plotHeight) + `"/></clipPath>`)
+	b.WriteString(`<rect x="` + coordinate(margin) + `" y="` + coordinate(margin) + `" width="` + coordinate(plotWidth) +
+		`" height="` + coordinate(plotHeight) + `" fill="none" stroke="#999"/>`)
+
+	// the axes, where they cross the view
+	if view.MinX < 0 && view.MaxX > 0 {
+		line(&b, toX(0), margin, toX(0), margin+plotHeight)
+	}
+	if view.MinY < 0 && view.MaxY > 0 {
+		line(&b, margin, toY(0), margin+plotWidth, toY(0))
+	}
+
+	label(&b, margin, margin+plotHeight+16, "start", view.MinX)
+	label(&b, margin+plotWidth, margin+plotHeight+16, "end", view.MaxX)
+	label(&b, margin-6, margin+plotHeight, "end", view.MinY)
+	label(&b, margin-6, margin+8, "end", view.MaxY)
+	if opts.Title != "" {
+		b.WriteString(`<text x="` + coordinate(float64(width)/2) + `" y="` + coordinate(margin/2) +
+			`" text-anchor="middle" font-size="14">` + html.EscapeString(opts.Title) + "</text>")
+	}
+
+	for _, segment := range view.Segments(points) {
+		b.WriteString(`<path fill="none" stroke="#1f77b4" stroke-width="1.5" clip-path="url(#plot)" d="`)
+		for i, point := range segment {
+			if i == 0 {
+				b.WriteString("M")
+			} else {
+				b.WriteString(" L")
+			}
+			// values far outside of the view are clamped, keeping the path within what renderers draw
+			y := math.Max(math.Min(toY(point.Y), 1e6), -1e6)
+			b.WriteString(coordinate(toX(point.X)) + " " + coordinate(y))
+		}
+		b.WriteString(`"/>`)
+	}
+	b.WriteString("</svg>")
+	return b.Bytes()
+}
+
+// line writes a line of the axes
+func line(b *bytes.Buffer, x1, y1, x2, y2 float64) {
+	b.WriteString(`<line x1="` + coordinate(x1) + `" y1="` + coordinate(y1) + `" x2="` + coordinate(x2) +
+		`" y2="` + coordinate(y2) + `" stroke="#ccc"/>`)
+}
+
+// label writes the value of a bound of an axis
+func label(b *bytes.Buffer, x, y float64, anchor string, value float64) {
+	b.WriteString(`<text x="` + coordinate(x) + `" y="` + coordinate(y) + `" text-anchor="` + anchor + `">` +
+		strconv.FormatFloat(value, 'g', 4, 64) + "</text>")
+}
+
+// coordinate formats a coordinate in pixels
+func coordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// CSV writes sampled points as comma separated values with a header, leaving out the values that are not finite
+func CSV(points []nparser.Point) []byte {
+	var b bytes.Buffer
+	b.WriteString("x,y\n")
+	for _, point := range points {
+		b.WriteString(strconv.FormatFloat(point.X, 'g', -1, 64) + ",")
+		if isFinite(point.Y) {
+			b.WriteString(strconv.FormatFloat(point.Y, 'g', -1, 64))
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
+// isFinite checks if a value is neither NaN nor infinite
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
@@ -0,0 +1,80 @@
+package nplot
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/viveknathani/numero/nparser"
+)
+
+func sample(t *testing.T, expression string, from, to float64, count int) []nparser.Point {
+	t.Helper()
+	program, err := nparser.Compile(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := program.Sample("x", from, to, count, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		expression string
+		from, to   float64
+		segments   int
+	}{
+		{"x ^ 2", -2, 2, 1},
+		{"sqrt(x)", -1, 1, 1},
+		{"log(x * x - 1)", -3, 3, 2},
+		{"tan(x)", -4, 4, 3},
+		{"1 / x", -1, 1, 2},
+	}
+
+	for _, test := range tests {
+		points := sample(t, test.expression, test.from, test.to, 200)
+		segments := NewView(points).Segments(points)
+		if len(segments) != test.segments {
+			t.Errorf("%s: expected %d segments, got %d", test.expression, test.segments, len(segments))
+		}
+	}
+}
+
+func TestNewView(t *testing.T) {
+	view := NewView(sample(t, "tan(x)", -4, 4, 400))
+	if view.MinX != -4 || view.MaxX != 4 {
+		t.Errorf("expected the view to span the samples, got %+v", view)
+	}
+	if view.MaxY > 100 || view.MinY < -100 {
+		t.Errorf("expected the view to leave out the asymptotes, got %+v", view)
+	}
+
+	view = NewView([]nparser.Point{{X: 0, Y: math.NaN()}})
+	if view.MinX >= view.MaxX || view.MinY >= view.MaxY {
+		t.Errorf("expected a non-empty view, got %+v", view)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	svg := SVG(sample(t, "1 / x", -1, 1, 100), Options{Title: "1 / x < 2"})
+	if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+		t.Fatalf("expected well-formed XML: %v", err)
+	}
+	if count := strings.Count(string(svg), "<path"); count != 2 {
+		t.Errorf("expected 2 paths, got %d", count)
+	}
+	if !strings.Contains(string(svg), `width="640" height="400"`) || !strings.Contains(string(svg), "1 / x &lt; 2") {
+		t.Errorf("unexpected chart %s", svg)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	csv := string(CSV([]nparser.Point{{X: 0, Y: 1.5}, {X: 0.5, Y: math.NaN()}, {X: 1, Y: math.Inf(1)}}))
+	if expected := "x,y\n0,1.5\n0.5,\n1,\n"; csv != expected {
+		t.Errorf("expected %q, got %q", expected, csv)
+	}
+}
@@ -0,0 +1,49 @@
+package nworkbook
+
+import "strings"
+
+// ErrInvalidName represents an error when a name cannot be referenced as a variable by expressions
+type ErrInvalidName struct {
+	Name string
+}
+
+func (e ErrInvalidName) Error() string {
+	return "invalid name: " + e.Name
+}
+
+// ErrInvalidDefinition represents an error when a definition is not of the form "name = expression"
+type ErrInvalidDefinition struct {
+	Definition string
+}
+
+func (e ErrInvalidDefinition) Error() string {
+	return "invalid definition, expected name = expression: " + e.Definition
+}
+
+// ErrUnknownName represents an error when a name is neither an input nor a formula
+type ErrUnknownName struct {
+	Name string
+}
+
+func (e ErrUnknownName) Error() string {
+	return "unknown name: " + e.Name
+}
+
+// ErrCycle represents an error when formulas reference each other in a cycle
+type ErrCycle struct {
+	// Path lists the formulas of the cycle, starting and ending with the same formula
+	Path []string
+}
+
+func (e ErrCycle) Error() string {
+	return "formulas reference each other in a cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// ErrDependency represents an error when a formula references a formula that failed
+type ErrDependency struct {
+	Name string
+}
+
+func (e ErrDependency) Error() string {
+	return "depends on " + e.Name + ", which failed"
+}
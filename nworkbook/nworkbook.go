@@ -0,0 +1,264 @@
+package nworkbook
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/viveknathani/numero/nparser"
+)
+
+// formula is a named expression of a workbook
+type formula struct {
+	expression string
+	program    *nparser.Program
+	// values holds the values of the program's variables by slot, reused across evaluations
+	values []float64
+}
+
+// Workbook holds named formulas that reference each other and named inputs, like the
+// cells of a spreadsheet. It keeps the value of every formula up to date, recomputing
+// only the formulas that depend on a change. A Workbook is not safe for concurrent use.
+type Workbook struct {
+	inputs   map[string]float64
+	formulas map[string]*formula
+	// dependents maps every name to the formulas referencing it
+	dependents map[string][]string
+	// order lists the formulas so that every formula comes after the formulas it references
+	order  []string
+	values map[string]float64
+	errors map[string]error
+}
+
+// New creates an empty workbook
+func New() *Workbook {
+	return &Workbook{
+		inputs:     make(map[string]float64),
+		formulas:   make(map[string]*formula),
+		dependents: make(map[string][]string),
+		values:     make(map[string]float64),
+		errors:     make(map[string]error),
+	}
+}
+
+// SetInput sets the value of an input, replacing the formula of the same name if any, and
+// recomputes the formulas depending on it. It returns the names of the recomputed formulas,
+// in the order they were recomputed.
+func (w *Workbook) SetInput(name string, value float64) ([]string, error) {
+	if !nparser.IsVariableName(name) {
+		return nil, ErrInvalidName{Name: name}
+	}
+	if _, ok := w.formulas[name]; ok {
+		delete(w.formulas, name)
+		// removing a formula never makes a cycle
+		w.rebuild()
+	}
+	w.inputs[name] = value
+	w.values[name] = value
+	delete(w.errors, name)
+	return w.recompute(name), nil
+}
+
+// SetFormula sets the expression of a formula, replacing the input of the same name if any,
+// and recomputes it along with the formulas depending on it. It returns the names of the
+// recomputed formulas, in the order they were recomputed. A formula that would make the
+// formulas reference themselves is rejected with an ErrCycle, leaving the workbook unchanged.
+func (w *Workbook) SetFormula(name, expression string) ([]string, error) {
+	if !nparser.IsVariableName(name) {
+		return nil, ErrInvalidName{Name: name}
+	}
+	program, err := nparser.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, hadFormula := w.formulas[name]
+	w.formulas[name] = &formula{
+		expression: expression,
+		program:    program,
+		values:     make([]float64, len(program.Variables())),
+	}
+	if err := w.rebuild(); err != nil {
+		if hadFormula {
+			w.formulas[name] = previous
+		} else {
+			delete(w.formulas, name)
+		}
+		w.rebuild()
+		return nil, err
+	}
+
+	delete(w.inputs, name)
+	return w.recompute(name), nil
+}
+
+// Define sets a formula from a definition of the form "name = expression"
+func (w *Workbook) Define(definition string) ([]string, error) {
+	name, expression, ok := strings.Cut(definition, "=")
+	if !ok {
+		return nil, ErrInvalidDefinition{Definition: definition}
+	}
+	return w.SetFormula(strings.TrimSpace(name), strings.TrimSpace(expression))
+}
+
+// Remove removes an input or formula, recomputing the formulas that depended on it
+func (w *Workbook) Remove(name string) []string {
+	_, isInput := w.inputs[name]
+	_, isFormula := w.formulas[name]
+	if !isInput && !isFormula {
+		return nil
+	}
+	delete(w.inputs, name)
+	delete(w.formulas, name)
+	delete(w.values, name)
+	delete(w.errors, name)
+	if isFormula {
+		// removing a formula never makes a cycle
+		w.rebuild()
+	}
+	return w.recompute(name)
+}
+
+// Value returns the value of an input or formula, or the error that its formula failed with
+func (w *Workbook) Value(name string) (float64, error) {
+	if err, ok := w.errors[name]; ok {
+		return 0, err
+	}
+	value, ok := w.values[name]
+	if !ok {
+		return 0, ErrUnknownName{Name: name}
+	}
+	return value, nil
+}
+
+// Expression returns the expression of a formula
+func (w *Workbook) Expression(name string) (string, bool) {
+	f, ok := w.formulas[name]
+	if !ok {
+		return "", false
+	}
+	return f.expression, true
+}
+
+// Formulas returns the names of the formulas, in an order where every formula comes after
+// the formulas it references
+func (w *Workbook) Formulas() []string {
+	return slices.Clone(w.order)
+}
+
+// Dependents returns the names of the formulas referencing a name directly
+func (w *Workbook) Dependents(name string) []string {
+	return slices.Clone(w.dependents[name])
+}
+
+// rebuild builds the dependency graph of the formulas and sorts them topologically,
+// failing if they reference each other in a cycle
+func (w *Workbook) rebuild() error {
+	names := make([]string, 0, len(w.formulas))
+	for name := range w.formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dependents := make(map[string][]string)
+	for _, name := range names {
+		for _, variable := range w.formulas[name].program.Variables() {
+			dependents[variable] = append(dependents[variable], name)
+		}
+	}
+
+	// a depth first search, where a formula met again while still on the path closes a cycle
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	order := make([]string, 0, len(names))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case onPath:
+			start := slices.Index(path, name)
+			return ErrCycle{Path: append(slices.Clone(path[start:]), name)}
+		case done:
+			return nil
+		}
+		state[name] = onPath
+		path = append(path, name)
+		for _, variable := range w.formulas[name].program.Variables() {
+			if _, ok := w.formulas[variable]; ok {
+				if err := visit(variable); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+
+	w.dependents = dependents
+	w.order = order
+	return nil
+}
+
+// recompute evaluates the formula of the given name, if any, and every formula depending
+// on it, directly or not, in topological order. It returns the names of the formulas evaluated.
+func (w *Workbook) recompute(name string) []string {
+	dirty := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, dependent := range w.dependents[next] {
+			if !dirty[dependent] {
+				dirty[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	var recomputed []string
+	for _, name := range w.order {
+		if dirty[name] {
+			w.evaluate(name)
+			recomputed = append(recomputed, name)
+		}
+	}
+	return recomputed
+}
+
+// evaluate evaluates a formula from the current values of the names it references
+func (w *Workbook) evaluate(name string) {
+	f := w.formulas[name]
+	delete(w.values, name)
+	delete(w.errors, name)
+
+	for slot, variable := range f.program.Variables() {
+		if _, failed := w.errors[variable]; failed {
+			w.errors[name] = ErrDependency{Name: variable}
+			return
+		}
+		value, ok := w.values[variable]
+		if !ok {
+			w.errors[name] = nparser.ErrUndefinedVariable{Variable: variable}
+			return
+		}
+		f.values[slot] = value
+	}
+
+	value, err := f.program.RunSlots(f.values)
+	if err != nil {
+		w.errors[name] = err
+		return
+	}
+	w.values[name] = value
+}
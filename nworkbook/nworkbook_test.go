@@ -0,0 +1,123 @@
+package nworkbook
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/viveknathani/numero/nparser"
+)
+
+func newInvoice(t *testing.T) *Workbook {
+	w := New()
+	definitions := []string{
+		"total = gross + shipping",
+		"gross = net * (1 + tax)",
+		"net = price * quantity",
+	}
+	for _, definition := range definitions {
+		if _, err := w.Define(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputs := map[string]float64{"price": 10, "quantity": 3, "tax": 0.2, "shipping": 5}
+	for name, value := range inputs {
+		if _, err := w.SetInput(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+func TestWorkbookEvaluatesInOrder(t *testing.T) {
+	w := newInvoice(t)
+
+	if order := w.Formulas(); !slices.Equal(order, []string{"net", "gross", "total"}) {
+		t.Errorf("expected the order [net gross total], got %v", order)
+	}
+	total, err := w.Value("total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(total-41) > 1e-9 {
+		t.Errorf("expected a total of 41, got %v", total)
+	}
+}
+
+func TestWorkbookRecomputesOnlyDependents(t *testing.T) {
+	w := newInvoice(t)
+
+	recomputed, err := w.SetInput("shipping", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(recomputed, []string{"total"}) {
+		t.Errorf("expected only total to be recomputed, got %v", recomputed)
+	}
+
+	recomputed, err = w.SetInput("tax", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(recomputed, []string{"gross", "total"}) {
+		t.Errorf("expected gross and total to be recomputed, got %v", recomputed)
+	}
+	if total, _ := w.Value("total"); total != 37 {
+		t.Errorf("expected a total of 37, got %v", total)
+	}
+}
+
+func TestWorkbookCycle(t *testing.T) {
+	w := newInvoice(t)
+
+	_, err := w.Define("net = total / 2")
+	var cycle ErrCycle
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+	if len(cycle.Path) != 4 || cycle.Path[0] != cycle.Path[3] {
+		t.Errorf("expected a cycle through the three formulas, got %v", cycle.Path)
+	}
+
+	// the workbook is left unchanged
+	if expression, _ := w.Expression("net"); expression != "price * quantity" {
+		t.Errorf("expected net to keep its expression, got %q", expression)
+	}
+	if total, err := w.Value("total"); err != nil || math.Abs(total-41) > 1e-9 {
+		t.Errorf("expected a total of 41, got %v (%v)", total, err)
+	}
+
+	if _, err := w.SetFormula("x", "x + 1"); !errors.As(err, &cycle) {
+		t.Errorf("expected ErrCycle for a formula referencing itself, got %v", err)
+	}
+}
+
+func TestWorkbookErrors(t *testing.T) {
+	w := newInvoice(t)
+
+	w.Remove("price")
+	if _, err := w.Value("net"); !errors.As(err, &nparser.ErrUndefinedVariable{}) {
+		t.Errorf("expected net to fail with ErrUndefinedVariable, got %v", err)
+	}
+	if _, err := w.Value("total"); !errors.As(err, &ErrDependency{}) {
+		t.Errorf("expected total to fail with ErrDependency, got %v", err)
+	}
+
+	if _, err := w.SetInput("price", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Value("total"); err != nil {
+		t.Errorf("expected total to recover, got %v", err)
+	}
+
+	if _, err := w.Value("missing"); !errors.As(err, &ErrUnknownName{}) {
+		t.Errorf("expected ErrUnknownName, got %v", err)
+	}
+	if _, err := w.SetInput("1x", 1); !errors.As(err, &ErrInvalidName{}) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := w.Define("no definition"); !errors.As(err, &ErrInvalidDefinition{}) {
+		t.Errorf("expected ErrInvalidDefinition, got %v", err)
+	}
+}